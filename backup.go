@@ -0,0 +1,200 @@
+package scribble
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// Backup writes a gzip-compressed tar archive of the database to w. If no
+// collections are given every collection is included, otherwise only the named
+// ones are. The manifest is always included. The read lock of each included
+// collection is held for the duration of the backup, so writers are paused and
+// the archive is a consistent snapshot. Temporary files left behind by
+// in-flight or interrupted writes are never included.
+func (d *Driver) Backup(w io.Writer, collections ...string) (err error) {
+	op := d.begin(context.Background(), "backup", "", "")
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return errors.ErrClosed
+	}
+
+	for _, collection := range collections {
+		if err := checkPath(collection, ""); err != nil {
+			return err
+		}
+	}
+
+	if len(collections) == 0 {
+		all, err := d.collections()
+		if err != nil {
+			return err
+		}
+		collections = all
+	}
+
+	// Lock in sorted order so concurrent backups cannot deadlock each other,
+	// and only once, since a waiting writer would block a second read lock.
+	collections = append([]string(nil), collections...)
+	sort.Strings(collections)
+	collections = slices.Compact(collections)
+
+	for _, collection := range collections {
+		mutex := d.getOrCreateLock(collection)
+		if err := op.rlock(mutex); err != nil {
+			return err
+		}
+		defer mutex.RUnlock()
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	n, err := addToArchive(tw, filepath.Join(d.dir, ManifestFile), ManifestFile)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.NewFileIOError(filepath.Join(d.dir, ManifestFile), err)
+	}
+	op.bytes += n
+
+	for _, collection := range collections {
+		n, err := d.backupCollection(tw, collection)
+		if err != nil {
+			return err
+		}
+		op.bytes += n
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+// backupCollection is a helper function for adding the records of a single
+// collection, with their shards and history, to a backup archive. It returns
+// the number of bytes archived.
+func (d *Driver) backupCollection(tw *tar.Writer, collection string) (int, error) {
+	dir := filepath.Join(d.dir, collection)
+	total := 0
+
+	err := walkCollection(dir, func(rel string, file fs.DirEntry) error {
+		if !file.Type().IsRegular() || strings.HasSuffix(rel, ".tmp") {
+			return nil
+		}
+
+		record := filepath.Join(dir, filepath.FromSlash(rel))
+		n, err := addToArchive(tw, record, path.Join(filepath.ToSlash(collection), rel))
+		total += n
+		if err != nil {
+			return errors.NewPathError(record, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return total, errors.NewPathError(dir, err)
+	}
+
+	return total, nil
+}
+
+// addToArchive is a helper function for copying a single file into a tar
+// archive. It returns the number of bytes copied.
+func addToArchive(tw *tar.Writer, record, name string) (int, error) {
+	f, err := os.Open(record)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	hdr, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return 0, err
+	}
+	hdr.Name = name
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(tw, f)
+	return int(n), err
+}
+
+// Restore rebuilds a database in dir from an archive produced by Backup. The
+// directory is created if needed and must otherwise be empty. The restored
+// database can then be opened with New.
+func Restore(r io.Reader, dir string) error {
+	dir = filepath.Clean(dir)
+
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return errors.ErrNotEmpty
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.NewFileIOError(dir, err)
+	}
+
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrInvalidArchive, err)
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", errors.ErrInvalidArchive, err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if !fs.ValidPath(hdr.Name) {
+			return fmt.Errorf("%w: illegal path %q", errors.ErrInvalidArchive, hdr.Name)
+		}
+
+		if err := restoreFile(tr, dir, hdr.Name); err != nil {
+			return err
+		}
+	}
+}
+
+// restoreFile is a helper function for extracting a single archived file.
+func restoreFile(r io.Reader, dir, name string) error {
+	dstPath := filepath.Join(dir, filepath.FromSlash(name))
+	parent := filepath.Dir(dstPath)
+
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return errors.NewFileIOError(parent, err)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrInvalidArchive, err)
+	}
+
+	return writeBytes(parent, dstPath+".tmp", dstPath, b)
+}
@@ -0,0 +1,77 @@
+package scribble
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestBackupAndRestore tests that a backup restores into an identical database.
+func TestBackupAndRestore(t *testing.T) {
+	src, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	for _, f := range []Fish{redfish, bluefish} {
+		if err := src.Write(collection, f.Type, f); err != nil {
+			t.Fatal("Create fish failed: ", err.Error())
+		}
+	}
+
+	// Simulate a write that was interrupted before its rename.
+	orphan := filepath.Join(src.dir, collection, "greenfish.json.tmp")
+	if err := os.WriteFile(orphan, []byte("{"), 0644); err != nil {
+		t.Fatal("Failed to create orphan: ", err.Error())
+	}
+
+	var buf bytes.Buffer
+	if err := src.Backup(&buf); err != nil {
+		t.Fatal("Backup failed: ", err.Error())
+	}
+
+	dir := filepath.Join(t.TempDir(), "restored")
+	if err := Restore(&buf, dir); err != nil {
+		t.Fatal("Restore failed: ", err.Error())
+	}
+
+	dst, err := New(dir, nil)
+	if err != nil {
+		t.Fatal("Failed to open restored database: ", err.Error())
+	}
+
+	fish := Fish{}
+	if err := dst.Read(collection, "blue", &fish); err != nil {
+		t.Error("Failed to read: ", err.Error())
+	}
+
+	if fish.Type != "blue" {
+		t.Error("Expected blue fish, got: ", fish.Type)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, collection, "greenfish.json.tmp")); err == nil {
+		t.Error("Expected temporary files to be left out of the backup")
+	}
+}
+
+// TestRestoreNotEmpty tests that restoring over an existing database is refused.
+func TestRestoreNotEmpty(t *testing.T) {
+	src, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := src.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	var buf bytes.Buffer
+	if err := src.Backup(&buf, collection); err != nil {
+		t.Fatal("Backup failed: ", err.Error())
+	}
+
+	if err := Restore(&buf, src.dir); err == nil {
+		t.Error("Allowed restore over an existing database")
+	}
+}
@@ -0,0 +1,236 @@
+package scribble
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// LostAndFound is the collection that Check moves damaged files into when
+// repairing. Each repair run gets its own sub-collection, named by a
+// time-sortable ID, that mirrors the layout of the collections it came from.
+const LostAndFound = "_lost+found"
+
+// IssueKind identifies a kind of problem found by Check.
+type IssueKind int
+
+const (
+	// IssueOrphanedTmp is a temporary file left behind by a write that was
+	// interrupted between writing the file and renaming it into place.
+	IssueOrphanedTmp IssueKind = iota
+
+	// IssueInvalidJSON is a record whose contents are not valid JSON.
+	IssueInvalidJSON
+
+	// IssueEmptyRecord is a record with no contents at all.
+	IssueEmptyRecord
+
+	// IssueForeignFile is a file inside a collection that is not a record.
+	IssueForeignFile
+
+	// IssueUnreadable is a record that cannot be decoded, either because its
+	// encryption key is unavailable or because it is corrupt.
+	IssueUnreadable
+)
+
+// String returns a short description of the issue kind.
+func (k IssueKind) String() string {
+	switch k {
+	case IssueOrphanedTmp:
+		return "orphaned temporary file"
+	case IssueInvalidJSON:
+		return "invalid JSON"
+	case IssueEmptyRecord:
+		return "empty record"
+	case IssueForeignFile:
+		return "not a record"
+	case IssueUnreadable:
+		return "unable to decode"
+	default:
+		return "unknown issue"
+	}
+}
+
+// Issue describes a single problem found by Check.
+type Issue struct {
+	Kind       IssueKind
+	Collection string
+	File       string // File is the slash-separated path of the offending file within the collection
+	Repaired   bool   // Repaired reports whether the file was moved into LostAndFound
+}
+
+// CheckOptions represents the optional configurations for Check.
+type CheckOptions struct {
+	// Repair moves every file with an issue into the LostAndFound collection.
+	Repair bool
+}
+
+// Report is the result of a Check.
+type Report struct {
+	Records int // Records is the number of healthy records seen
+	Issues  []Issue
+}
+
+// Check scans every collection in the database for damaged or stray files:
+// temporary files orphaned by a crash during a write, records that are empty
+// or not valid JSON, and files that are not records at all. Each collection is
+// locked while it is checked, so in-flight writes are never reported. With
+// Repair set, offending files are moved into the LostAndFound collection.
+func (d *Driver) Check(options *CheckOptions) (report Report, err error) {
+	op := d.begin(context.Background(), "check", "", "")
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return report, errors.ErrClosed
+	}
+
+	opts := CheckOptions{}
+
+	if options != nil {
+		opts = *options
+	}
+
+	if opts.Repair && d.readOnly {
+		return report, errors.ErrReadOnly
+	}
+
+	collections, err := d.collections()
+	if err != nil {
+		return report, err
+	}
+
+	quarantine := ""
+	if opts.Repair {
+		id, err := newID()
+		if err != nil {
+			return report, err
+		}
+		quarantine = path.Join(LostAndFound, id)
+	}
+
+	for _, collection := range collections {
+		if internal(collection) {
+			continue
+		}
+
+		if err := d.checkCollection(op, collection, quarantine, &report); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// checkCollection is a helper function for checking the files stored directly
+// in a single collection, quarantining them if quarantine is set.
+func (d *Driver) checkCollection(op *operation, collection, quarantine string, report *Report) error {
+	mutex := d.getOrCreateLock(collection)
+	if err := op.lock(mutex); err != nil {
+		return err
+	}
+	defer mutex.Unlock()
+
+	dir := filepath.Join(d.dir, collection)
+	err := walkCollection(dir, func(rel string, file fs.DirEntry) error {
+		kind, ok, err := d.checkFile(dir, rel, file)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			// Previous versions are checked, but are not records.
+			if !strings.HasPrefix(rel, historyDir+"/") {
+				report.Records++
+			}
+			return nil
+		}
+
+		issue := Issue{Kind: kind, Collection: collection, File: rel}
+
+		if quarantine != "" {
+			if err := d.quarantine(quarantine, collection, rel); err != nil {
+				return err
+			}
+			issue.Repaired = true
+		}
+
+		report.Issues = append(report.Issues, issue)
+		return nil
+	})
+	if err != nil {
+		return errors.NewPathError(dir, err)
+	}
+
+	return nil
+}
+
+// checkFile is a helper function for classifying a single file within a
+// collection, reporting whether it is a healthy record.
+func (d *Driver) checkFile(dir, rel string, file fs.DirEntry) (IssueKind, bool, error) {
+	name := file.Name()
+
+	switch {
+	case strings.HasSuffix(name, ".tmp"):
+		return IssueOrphanedTmp, false, nil
+	case !file.Type().IsRegular():
+		return IssueForeignFile, false, nil
+	}
+
+	if _, ok := recordID(name); !ok {
+		return IssueForeignFile, false, nil
+	}
+
+	record := filepath.Join(dir, filepath.FromSlash(rel))
+	b, _, err := d.readRecord(record)
+	switch {
+	case stderrors.Is(err, errors.ErrKeyNotFound), stderrors.Is(err, errors.ErrCorruptRecord):
+		return IssueUnreadable, false, nil
+	case err != nil:
+		return 0, false, errors.NewPathError(record, err)
+	}
+
+	switch {
+	case len(b) == 0:
+		return IssueEmptyRecord, false, nil
+	case !json.Valid(b):
+		return IssueInvalidJSON, false, nil
+	}
+
+	return 0, true, nil
+}
+
+// quarantine is a helper function for moving a damaged file, given by its
+// slash-separated path within its collection, out of the collection and into
+// the lost and found.
+func (d *Driver) quarantine(quarantine, collection, rel string) error {
+	dst := filepath.Join(d.dir, quarantine, collection, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.NewFileIOError(filepath.Dir(dst), err)
+	}
+
+	src := filepath.Join(d.dir, collection, filepath.FromSlash(rel))
+	if err := os.Rename(src, dst); err != nil {
+		return errors.NewFileIOError(src, err)
+	}
+
+	return nil
+}
+
+// internal reports whether a collection is, or is inside, one of the
+// collections the driver manages itself: LostAndFound and Trash.
+func internal(collection string) bool {
+	for _, c := range []string{LostAndFound, Trash} {
+		if collection == c || strings.HasPrefix(collection, c+"/") {
+			return true
+		}
+	}
+
+	return false
+}
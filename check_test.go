@@ -0,0 +1,101 @@
+package scribble
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// createDamagedSchool creates a database holding one healthy fish and one file
+// of every kind of damage Check looks for.
+func createDamagedSchool(t *testing.T) *Driver {
+	d, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	for name, contents := range map[string]string{
+		"blue.json.tmp": `{"type":"blue"}`,
+		"green.json":    `{"type":`,
+		"yellow.json":   ``,
+		"notes.txt":     `not a fish`,
+	} {
+		if err := os.WriteFile(filepath.Join(d.dir, collection, name), []byte(contents), 0644); err != nil {
+			t.Fatal("Failed to create damaged file: ", err.Error())
+		}
+	}
+
+	return d
+}
+
+// TestCheck tests that every kind of damage is reported.
+func TestCheck(t *testing.T) {
+	d := createDamagedSchool(t)
+
+	report, err := d.Check(nil)
+	if err != nil {
+		t.Fatal("Check failed: ", err.Error())
+	}
+
+	if report.Records != 1 {
+		t.Errorf("Expected 1 healthy record, got: %d", report.Records)
+	}
+
+	found := map[string]IssueKind{}
+	for _, issue := range report.Issues {
+		found[issue.File] = issue.Kind
+		if issue.Repaired {
+			t.Error("Expected no repairs, got: ", issue.File)
+		}
+	}
+
+	expected := map[string]IssueKind{
+		"blue.json.tmp": IssueOrphanedTmp,
+		"green.json":    IssueInvalidJSON,
+		"yellow.json":   IssueEmptyRecord,
+		"notes.txt":     IssueForeignFile,
+	}
+	for name, kind := range expected {
+		if got, ok := found[name]; !ok || got != kind {
+			t.Errorf("Expected %s to be reported as %v, got: %v", name, kind, got)
+		}
+	}
+
+	if len(report.Issues) != len(expected) {
+		t.Errorf("Expected %d issues, got: %d", len(expected), len(report.Issues))
+	}
+}
+
+// TestCheckRepair tests that repair moves damaged files into the lost and found.
+func TestCheckRepair(t *testing.T) {
+	d := createDamagedSchool(t)
+
+	report, err := d.Check(&CheckOptions{Repair: true})
+	if err != nil {
+		t.Fatal("Check failed: ", err.Error())
+	}
+
+	for _, issue := range report.Issues {
+		if !issue.Repaired {
+			t.Error("Expected repair of: ", issue.File)
+		}
+	}
+
+	ids, err := d.List(collection)
+	if err != nil || len(ids) != 1 || ids[0] != "red" {
+		t.Error("Expected only the red fish to remain, got: ", ids)
+	}
+
+	report, err = d.Check(nil)
+	if err != nil {
+		t.Fatal("Check failed: ", err.Error())
+	}
+
+	if len(report.Issues) != 0 {
+		t.Error("Expected a clean database after repair, got: ", report.Issues)
+	}
+}
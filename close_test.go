@@ -0,0 +1,100 @@
+package scribble
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// TestClose tests that a closed driver refuses every operation and releases its locks.
+func TestClose(t *testing.T) {
+	d, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatal("Close failed: ", err.Error())
+	}
+
+	d.resourceLocks.Range(func(key, _ interface{}) bool {
+		t.Error("Expected no locks, got one for: ", key)
+		return true
+	})
+
+	if err := d.Read(collection, "red", &onefish); !stderrors.Is(err, errors.ErrClosed) {
+		t.Error("Expected ErrClosed reading, got: ", err)
+	}
+
+	if err := d.Write(collection, "blue", bluefish); !stderrors.Is(err, errors.ErrClosed) {
+		t.Error("Expected ErrClosed writing, got: ", err)
+	}
+
+	if _, err := d.ReadAll(collection); !stderrors.Is(err, errors.ErrClosed) {
+		t.Error("Expected ErrClosed reading all, got: ", err)
+	}
+
+	if _, err := d.List(collection); !stderrors.Is(err, errors.ErrClosed) {
+		t.Error("Expected ErrClosed listing, got: ", err)
+	}
+
+	if _, err := d.Collections(); !stderrors.Is(err, errors.ErrClosed) {
+		t.Error("Expected ErrClosed listing collections, got: ", err)
+	}
+
+	if err := d.Close(context.Background()); err != nil {
+		t.Error("Expected closing twice to succeed, got: ", err)
+	}
+}
+
+// TestCloseWaits tests that Close waits for operations in flight, and gives up
+// when its context is done.
+func TestCloseWaits(t *testing.T) {
+	d, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+
+	go func() {
+		done <- d.Update(collection, "red", func(current json.RawMessage) (interface{}, error) {
+			close(started)
+			<-release
+			return redfish, nil
+		})
+	}()
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := d.Close(ctx); !stderrors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected Close to time out, got: ", err)
+	}
+
+	if err := d.Write(collection, "blue", bluefish); !stderrors.Is(err, errors.ErrClosed) {
+		t.Error("Expected ErrClosed while closing, got: ", err)
+	}
+
+	close(release)
+
+	if err := <-done; err != nil {
+		t.Error("Expected update in flight to finish, got: ", err)
+	}
+
+	if err := d.Close(context.Background()); err != nil {
+		t.Error("Close failed: ", err)
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/D7682/scribble"
+	"github.com/D7682/scribble/pkg/server"
+)
+
+// errUsage is returned when a command is invoked with the wrong arguments.
+var errUsage = errors.New("usage: scribble [-dir path] [-read-only] <ls|get|put|rm|drop|export|import|stats|fsck|serve> [arguments]")
+
+// command runs a subcommand against an open database.
+type command func(a *app, args []string) error
+
+// commands maps subcommand names to their implementations.
+var commands = map[string]command{
+	"ls":     (*app).ls,
+	"get":    (*app).get,
+	"put":    (*app).put,
+	"rm":     (*app).rm,
+	"drop":   (*app).drop,
+	"export": (*app).export,
+	"import": (*app).importRecords,
+	"stats":  (*app).stats,
+	"fsck":   (*app).fsck,
+	"serve":  (*app).serve,
+}
+
+// readCommands are the subcommands that never modify the database. They open
+// it read-only, so a mistyped -dir is reported instead of creating a database.
+// fsck is opened read-only too unless it is asked to repair.
+var readCommands = map[string]bool{
+	"ls":     true,
+	"get":    true,
+	"export": true,
+	"stats":  true,
+}
+
+// app holds the state shared by all subcommands.
+type app struct {
+	db     *scribble.Driver
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+// newApp creates a new app reading from stdin and writing to stdout.
+func newApp(stdin io.Reader, stdout io.Writer) *app {
+	return &app{stdin: stdin, stdout: stdout}
+}
+
+// run parses the global flags, opens the database and runs the subcommand.
+func (a *app) run(args []string) error {
+	fs := flag.NewFlagSet("scribble", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	dir := fs.String("dir", ".", "path to the database")
+	readOnly := fs.Bool("read-only", false, "open the database without modifying it")
+
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		return errUsage
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%w", fs.Arg(0), errUsage)
+	}
+
+	ro := *readOnly || readCommands[fs.Arg(0)]
+	if fs.Arg(0) == "fsck" {
+		repair, err := fsckFlags(fs.Args()[1:])
+		ro = ro || err != nil || !repair
+	}
+
+	db, err := scribble.New(*dir, &scribble.Options{ReadOnly: ro})
+	if err != nil {
+		return err
+	}
+	a.db = db
+
+	err = cmd(a, fs.Args()[1:])
+	if cerr := db.Close(context.Background()); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// ls lists the collections in the database, or the records in a collection.
+func (a *app) ls(args []string) error {
+	var (
+		names []string
+		err   error
+	)
+
+	switch len(args) {
+	case 0:
+		names, err = a.db.Collections()
+	case 1:
+		names, err = a.db.List(args[0])
+	default:
+		return errUsage
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		fmt.Fprintln(a.stdout, name)
+	}
+
+	return nil
+}
+
+// get prints a single record.
+func (a *app) get(args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	raw, err := a.db.ReadRaw(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(a.stdout, string(bytes.TrimSpace(raw)))
+	return err
+}
+
+// put writes a single record read from stdin.
+func (a *app) put(args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	b, err := io.ReadAll(a.stdin)
+	if err != nil {
+		return err
+	}
+
+	return a.db.WriteRaw(args[0], args[1], b)
+}
+
+// rm deletes a single record.
+func (a *app) rm(args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	return a.db.Delete(args[0], args[1])
+}
+
+// drop deletes a whole collection.
+func (a *app) drop(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	return a.db.DropCollection(args[0])
+}
+
+// export writes a collection to stdout as NDJSON.
+func (a *app) export(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	return a.db.Export(args[0], a.stdout)
+}
+
+// importRecords reads NDJSON from stdin into a collection.
+func (a *app) importRecords(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	skip := fs.Bool("skip-existing", false, "leave existing records untouched")
+
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	opts := &scribble.ImportOptions{Mode: scribble.ImportUpsert}
+	if *skip {
+		opts.Mode = scribble.ImportSkipExisting
+	}
+
+	n, err := a.db.Import(fs.Arg(0), a.stdin, opts)
+	fmt.Fprintf(a.stdout, "imported %d records\n", n)
+	return err
+}
+
+// stats prints the number of records, their sizes and their ages for each
+// collection, followed by a total for the whole database when no collections
+// are named.
+func (a *app) stats(args []string) error {
+	collections, err := a.collections(args)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "COLLECTION\tRECORDS\tBYTES\tLARGEST\tOLDEST\tNEWEST\tTMP")
+
+	for _, collection := range collections {
+		stats, err := a.db.Stats(collection)
+		if err != nil {
+			return err
+		}
+
+		printStats(tw, collection, stats)
+	}
+
+	if len(args) == 0 {
+		stats, err := a.db.DBStats()
+		if err != nil {
+			return err
+		}
+
+		printStats(tw, "TOTAL", stats)
+	}
+
+	return tw.Flush()
+}
+
+// printStats prints a single row of the stats table.
+func printStats(w io.Writer, name string, stats scribble.Stats) {
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\t%d\n", name, stats.Records, stats.Bytes, stats.Largest,
+		formatTime(stats.Oldest), formatTime(stats.Newest), stats.OrphanedTmp)
+}
+
+// formatTime formats a time for the stats table, or returns "-" if it is unset.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// fsck checks the database for damaged or stray files, optionally moving them
+// into the lost and found. It fails if any unrepaired problems are found.
+func (a *app) fsck(args []string) error {
+	repair, err := fsckFlags(args)
+	if err != nil {
+		return err
+	}
+
+	report, err := a.db.Check(&scribble.CheckOptions{Repair: repair})
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range report.Issues {
+		status := ""
+		if issue.Repaired {
+			status = " (moved to " + scribble.LostAndFound + ")"
+		}
+		fmt.Fprintf(a.stdout, "%s/%s: %v%s\n", issue.Collection, issue.File, issue.Kind, status)
+	}
+
+	fmt.Fprintf(a.stdout, "%d records, %d issues\n", report.Records, len(report.Issues))
+
+	if len(report.Issues) > 0 && !repair {
+		return fmt.Errorf("found %d problems", len(report.Issues))
+	}
+
+	return nil
+}
+
+// fsckFlags parses the arguments of fsck, reporting whether -repair was given.
+func fsckFlags(args []string) (bool, error) {
+	fs := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	repair := fs.Bool("repair", false, "move damaged files into "+scribble.LostAndFound)
+
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return false, errUsage
+	}
+
+	return *repair, nil
+}
+
+// serve exposes the database over HTTP until the process is stopped.
+func (a *app) serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	allowDrop := fs.Bool("allow-drop", false, "allow DELETE of whole collections")
+	maxBody := fs.Int64("max-body", 0, "largest request body accepted, in bytes (default 8 MiB)")
+
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+
+	fmt.Fprintf(a.stdout, "serving on %s\n", *addr)
+	return http.ListenAndServe(*addr, server.New(a.db, &server.Options{AllowDrop: *allowDrop, MaxBodyBytes: *maxBody}))
+}
+
+// collections returns the collections named in args, or every collection in
+// the database if none are named.
+func (a *app) collections(args []string) ([]string, error) {
+	if len(args) > 0 {
+		return args, nil
+	}
+
+	collections, err := a.db.Collections()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(collections)
+	return collections, nil
+}
@@ -0,0 +1,45 @@
+// Command scribble inspects and edits scribble databases from the command line.
+//
+// Every command goes through scribble.Driver, so edits keep the same locking
+// and atomic write guarantees as the library.
+//
+// Usage:
+//
+//	scribble [-dir path] [-read-only] <command> [arguments]
+//
+// With -read-only the database is never modified, so it can live on a
+// read-only filesystem; commands that would change it fail.
+//
+// Commands:
+//
+//	ls [collection]             list collections, or the records in a collection
+//	get <collection> <id>       print a record
+//	put <collection> <id>       write a record read from stdin
+//	rm <collection> <id>        delete a record
+//	drop <collection>           delete a whole collection, with its history and sub-collections
+//	export <collection>         write a collection to stdout as NDJSON
+//	import [-skip-existing] <collection>
+//	                            read NDJSON from stdin into a collection
+//	stats [collection]          print record counts, sizes and ages, with a total
+//	fsck [-repair]              check for damaged files, optionally quarantining them
+//	serve [-addr :8080] [-allow-drop] [-max-body bytes]
+//	                            expose the database over HTTP
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "scribble:", err)
+		os.Exit(1)
+	}
+}
+
+// run parses the global flags and dispatches to a command.
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	return newApp(stdin, stdout).run(args)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runCLI runs the command line tool against dir with the given stdin,
+// returning its output.
+func runCLI(t *testing.T, dir, stdin string, args ...string) (string, error) {
+	t.Helper()
+
+	var out bytes.Buffer
+	err := run(append([]string{"-dir", dir}, args...), strings.NewReader(stdin), &out)
+	return out.String(), err
+}
+
+// TestPutGetRemove tests a round trip of a record through the command line tool.
+func TestPutGetRemove(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := runCLI(t, dir, `{"type":"red"}`, "put", "fish", "red"); err != nil {
+		t.Fatal("put failed: ", err.Error())
+	}
+
+	out, err := runCLI(t, dir, "", "get", "fish", "red")
+	if err != nil {
+		t.Fatal("get failed: ", err.Error())
+	}
+
+	if out != `{"type":"red"}`+"\n" {
+		t.Error("Expected red fish, got: ", out)
+	}
+
+	out, _ = runCLI(t, dir, "", "ls", "fish")
+	if out != "red\n" {
+		t.Error("Expected one fish listed, got: ", out)
+	}
+
+	if _, err := runCLI(t, dir, "", "rm", "fish", "red"); err != nil {
+		t.Fatal("rm failed: ", err.Error())
+	}
+
+	if _, err := runCLI(t, dir, "", "get", "fish", "red"); err == nil {
+		t.Error("Expected nothing, got fish")
+	}
+}
+
+// TestPutInvalid tests that put refuses input that is not JSON.
+func TestPutInvalid(t *testing.T) {
+	if _, err := runCLI(t, t.TempDir(), "{", "put", "fish", "red"); err == nil {
+		t.Error("Allowed put of invalid JSON")
+	}
+}
+
+// TestExportImport tests moving a collection between databases with the command line tool.
+func TestExportImport(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+
+	if _, err := runCLI(t, src, `{"type":"blue"}`, "put", "fish", "blue"); err != nil {
+		t.Fatal("put failed: ", err.Error())
+	}
+
+	out, err := runCLI(t, src, "", "export", "fish")
+	if err != nil {
+		t.Fatal("export failed: ", err.Error())
+	}
+
+	if _, err := runCLI(t, dst, out, "import", "-skip-existing", "fish"); err != nil {
+		t.Fatal("import failed: ", err.Error())
+	}
+
+	out, _ = runCLI(t, dst, "", "fsck")
+	if out != "1 records, 0 issues\n" {
+		t.Error("Expected clean database, got: ", out)
+	}
+}
+
+// TestUnknownCommand tests that unknown commands are rejected.
+func TestUnknownCommand(t *testing.T) {
+	if _, err := runCLI(t, t.TempDir(), "", "frobnicate"); err == nil {
+		t.Error("Allowed unknown command")
+	}
+}
+
+// TestReadOnly tests that -read-only allows reads and refuses writes.
+func TestReadOnly(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := runCLI(t, dir, `{"type":"red"}`, "put", "fish", "red"); err != nil {
+		t.Fatal("put failed: ", err.Error())
+	}
+
+	if _, err := runCLI(t, dir, "", "-read-only", "get", "fish", "red"); err != nil {
+		t.Error("get failed: ", err.Error())
+	}
+
+	if _, err := runCLI(t, dir, "", "-read-only", "rm", "fish", "red"); err == nil {
+		t.Error("Allowed rm of a read-only database")
+	}
+}
+
+// TestStats tests that stats reports the records of each collection.
+func TestStats(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := runCLI(t, dir, `{"type":"red"}`, "put", "fish", "red"); err != nil {
+		t.Fatal("put failed: ", err.Error())
+	}
+
+	out, err := runCLI(t, dir, "", "stats")
+	if err != nil {
+		t.Fatal("stats failed: ", err.Error())
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "fish ") || strings.Fields(lines[1])[1] != "1" {
+		t.Fatal("Expected one fish, got: ", out)
+	}
+
+	if fields := strings.Fields(lines[2]); fields[0] != "TOTAL" || fields[1] != "1" || fields[4] == "-" {
+		t.Error("Expected a total with the oldest record's time, got: ", out)
+	}
+
+	out, _ = runCLI(t, dir, "", "stats", "fish")
+	if strings.Contains(out, "TOTAL") {
+		t.Error("Expected no total for named collections, got: ", out)
+	}
+}
+
+// TestDrop tests that rm needs an ID and drop deletes a whole collection.
+func TestDrop(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := runCLI(t, dir, `{"type":"red"}`, "put", "fish", "red"); err != nil {
+		t.Fatal("put failed: ", err.Error())
+	}
+
+	if _, err := runCLI(t, dir, "", "rm", "fish"); err == nil {
+		t.Error("Allowed rm without an ID")
+	}
+
+	if _, err := runCLI(t, dir, "", "drop", "fish"); err != nil {
+		t.Fatal("drop failed: ", err.Error())
+	}
+
+	if out, _ := runCLI(t, dir, "", "ls"); out != "" {
+		t.Error("Expected no collections, got: ", out)
+	}
+}
+
+// TestReadCommands tests that read-only commands never create or modify a database.
+func TestReadCommands(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "typo")
+
+	if _, err := runCLI(t, dir, "", "get", "fish", "red"); err == nil {
+		t.Error("Expected get in a missing directory to fail")
+	}
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("Expected no database to be created, got: ", err)
+	}
+
+	if _, err := runCLI(t, dir, "", "fsck"); err == nil {
+		t.Error("Expected fsck of a missing directory to fail")
+	}
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("Expected fsck not to create a database, got: ", err)
+	}
+
+	dir = t.TempDir()
+	if _, err := runCLI(t, dir, "", "ls"); err != nil {
+		t.Fatal("ls failed: ", err.Error())
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Error("Expected ls to leave the directory empty, got: ", entries)
+	}
+}
@@ -0,0 +1,224 @@
+package scribble
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+const (
+	// recordExt is the extension of a plain record file.
+	recordExt = ".json"
+
+	// compressedExt is the extension of a gzip compressed record file.
+	compressedExt = ".json.gz"
+)
+
+// recordExts is a helper function for returning the extension new records in
+// a collection are written with, followed by the alternative extension.
+func (d *Driver) recordExts(collection string) (string, string) {
+	if d.compresses(collection) {
+		return compressedExt, recordExt
+	}
+	return recordExt, compressedExt
+}
+
+// recordPaths is a helper function for listing every path a record in a
+// directory may be stored at: with either extension and, directly inside a
+// collection, in either the flat or the sharded layout. The first path is the
+// one new versions of the record are written to.
+func (d *Driver) recordPaths(collection, dir, resource string) []string {
+	ext, alt := d.recordExts(collection)
+
+	dirs := []string{dir}
+	if dir == filepath.Join(d.dir, collection) {
+		if d.shards(collection) {
+			dirs = []string{shardDir(dir, resource), dir}
+		} else {
+			dirs = append(dirs, shardDir(dir, resource))
+		}
+	}
+
+	var paths []string
+	for _, dir := range dirs {
+		paths = append(paths, filepath.Join(dir, resource+ext), filepath.Join(dir, resource+alt))
+	}
+
+	return paths
+}
+
+// findRecord is a helper function for locating the file holding a record,
+// wherever and with whichever extension it was stored. If there is no such
+// file, it returns the path the record would be written to along with the
+// unwrapped error from os.Stat, so callers can test it with os.IsNotExist.
+func (d *Driver) findRecord(collection, dir, resource string) (string, error) {
+	paths := d.recordPaths(collection, dir, resource)
+
+	var err error
+	for _, path := range paths {
+		if _, err = os.Stat(path); !os.IsNotExist(err) {
+			return path, err
+		}
+	}
+
+	return paths[0], err
+}
+
+// writeRecord is a helper function for encoding the JSON of a record and
+// atomically writing it to its collection, after the manifest if it is still
+// pending. Any copy of the record stored
+// elsewhere, with the other extension or in the other layout, is removed. It
+// returns the number of bytes written.
+func (d *Driver) writeRecord(collection, dir, resource string, b []byte) (int, error) {
+	if err := d.saveManifest(); err != nil {
+		return 0, err
+	}
+
+	b, err := d.encode(collection, b)
+	if err != nil {
+		return 0, err
+	}
+
+	paths := d.recordPaths(collection, dir, resource)
+
+	dstPath := paths[0]
+	if dstDir := filepath.Dir(dstPath); dstDir != dir {
+		if err := os.MkdirAll(dstDir, 0755); err != nil {
+			return 0, errors.NewFileIOError(dstDir, err)
+		}
+	}
+
+	if err := writeBytes(dir, dstPath+".tmp", dstPath, b); err != nil {
+		return 0, err
+	}
+
+	return len(b), removeFiles(paths[1:])
+}
+
+// removeRecord is a helper function for removing a record, wherever and with
+// whichever extension it was stored.
+func (d *Driver) removeRecord(collection, dir, resource string) error {
+	return removeFiles(d.recordPaths(collection, dir, resource))
+}
+
+// removeFiles is a helper function for removing files, ignoring any that do
+// not exist.
+func removeFiles(paths []string) error {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return errors.NewFileIOError(path, err)
+		}
+	}
+
+	return nil
+}
+
+// recordID is a helper function for returning the ID of the record stored in
+// a file, reporting whether the file name has a record extension.
+func recordID(name string) (string, bool) {
+	for _, ext := range []string{compressedExt, recordExt} {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext), true
+		}
+	}
+
+	return "", false
+}
+
+// encode is a helper function for transforming the JSON of a record into the
+// bytes stored on disk, as configured for its collection. Records are
+// compressed before they are encrypted, since ciphertext does not compress.
+func (d *Driver) encode(collection string, b []byte) ([]byte, error) {
+	if d.compresses(collection) {
+		var buf bytes.Buffer
+
+		level := d.compression.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+
+		zw, err := gzip.NewWriterLevel(&buf, level)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := zw.Write(b); err != nil {
+			return nil, err
+		}
+
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+
+		b = buf.Bytes()
+	}
+
+	if d.encrypts(collection) {
+		return d.encryption.seal(b)
+	}
+
+	return b, nil
+}
+
+// decode is a helper function for recovering the JSON of a record from the
+// bytes stored in the named file. Encryption is detected from the data and
+// compression from the file extension, so records written under a different
+// configuration remain readable.
+func (d *Driver) decode(name string, b []byte) ([]byte, error) {
+	if isSealed(b) {
+		var err error
+		if b, err = d.encryption.open(b); err != nil {
+			return nil, err
+		}
+	}
+
+	if strings.HasSuffix(name, compressedExt) {
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrCorruptRecord, err)
+		}
+		defer zr.Close()
+
+		if b, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrCorruptRecord, err)
+		}
+	}
+
+	return b, nil
+}
+
+// readRecord is a helper function for reading and decoding a record file. It
+// returns the JSON of the record and the number of bytes read from disk.
+// Errors from reading the file are returned unwrapped, so callers can test
+// them with os.IsNotExist.
+func (d *Driver) readRecord(path string) ([]byte, int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	json, err := d.decode(path, b)
+	return json, len(b), err
+}
+
+// inScope reports whether a per-collection option limited to the given
+// collections applies to collection. An empty list applies to every collection.
+func inScope(collections []string, collection string) bool {
+	if len(collections) == 0 {
+		return true
+	}
+
+	for _, c := range collections {
+		if c == collection {
+			return true
+		}
+	}
+
+	return false
+}
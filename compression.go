@@ -0,0 +1,19 @@
+package scribble
+
+// Compression represents the configuration for compressing records with gzip.
+// Compressed records are stored with the ".json.gz" extension instead of
+// ".json"; reads accept either, so compression can be switched on or off for
+// a collection without rewriting it.
+type Compression struct {
+	// Level is the gzip compression level. Zero selects the default level.
+	Level int
+
+	// Collections limits compression to the named collections. If empty,
+	// every collection is compressed.
+	Collections []string
+}
+
+// compresses reports whether new records in a collection are compressed.
+func (d *Driver) compresses(collection string) bool {
+	return d.compression != nil && inScope(d.compression.Collections, collection)
+}
@@ -0,0 +1,97 @@
+package scribble
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCompression tests that compressed records round trip under their own extension.
+func TestCompression(t *testing.T) {
+	d := newTestDriver(t, t.TempDir(), &Options{Compression: &Compression{}})
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	b, err := os.ReadFile(filepath.Join(d.dir, collection, "red.json.gz"))
+	if err != nil || !bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		t.Fatal("Expected gzip record on disk, got: ", err)
+	}
+
+	if err := d.Read(collection, "red", &onefish); err != nil || onefish != redfish {
+		t.Error("Expected redfish, got: ", onefish, err)
+	}
+
+	if ids, err := d.List(collection); err != nil || len(ids) != 1 || ids[0] != "red" {
+		t.Error("Expected the red fish to be listed, got: ", ids, err)
+	}
+
+	if err := d.Delete(collection, "red"); err != nil {
+		t.Fatal("Delete fish failed: ", err.Error())
+	}
+
+	if _, err := os.Stat(filepath.Join(d.dir, collection, "red.json.gz")); !os.IsNotExist(err) {
+		t.Error("Expected compressed record to be removed, got: ", err)
+	}
+}
+
+// TestCompressionToggle tests that plain and compressed records are read
+// interchangeably and rewritten under the current setting.
+func TestCompressionToggle(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := newTestDriver(t, dir, &Options{Compression: &Compression{}}).Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	plain, err := New(dir, &Options{Reconfigure: true})
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := plain.Write(collection, "blue", bluefish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	records, err := plain.ReadAll(collection)
+	if err != nil || len(records) != 2 {
+		t.Error("Expected plain and compressed records, got: ", len(records), err)
+	}
+
+	if err := plain.Write(collection, "red", bluefish); err != nil {
+		t.Fatal("Update fish failed: ", err.Error())
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, collection, "red.json.gz")); !os.IsNotExist(err) {
+		t.Error("Expected stale compressed copy to be removed, got: ", err)
+	}
+
+	if err := plain.Read(collection, "red", &onefish); err != nil || onefish != bluefish {
+		t.Error("Expected bluefish, got: ", onefish, err)
+	}
+}
+
+// TestCompressionEncryption tests that records can be compressed and encrypted together.
+func TestCompressionEncryption(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{
+		Compression: &Compression{},
+		Encryption:  &Encryption{Keys: testKeys()},
+	})
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Read(collection, "red", &onefish); err != nil || onefish != redfish {
+		t.Error("Expected redfish, got: ", onefish, err)
+	}
+
+	if report, err := d.Check(nil); err != nil || report.Records != 1 || len(report.Issues) != 0 {
+		t.Error("Expected a healthy record, got: ", report, err)
+	}
+}
@@ -0,0 +1,351 @@
+package scribble
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// sealedMagic prefixes every encrypted record. It starts with a NUL byte, so
+// it can never be mistaken for the start of a JSON document.
+var sealedMagic = []byte("\x00scribble-aesgcm\x01")
+
+// KeyProvider supplies the AES keys used to encrypt records. Every key is
+// identified by an ID that is stored in the header of the records it
+// encrypted, so keys can be rotated: new records use the current key while
+// older ones stay readable for as long as their key can still be looked up.
+type KeyProvider interface {
+	// CurrentKey returns the ID and value of the key new records are
+	// encrypted with. The key must be 16, 24 or 32 bytes long.
+	CurrentKey() (id string, key []byte, err error)
+
+	// Key returns the key with the given ID, for decrypting existing records.
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeys is a KeyProvider backed by a fixed set of keys.
+type StaticKeys struct {
+	Current string            // Current is the ID of the key used for new records
+	Keys    map[string][]byte // Keys maps key IDs to AES keys
+}
+
+// CurrentKey implements KeyProvider for StaticKeys.
+func (k StaticKeys) CurrentKey() (string, []byte, error) {
+	key, err := k.Key(k.Current)
+	return k.Current, key, err
+}
+
+// Key implements KeyProvider for StaticKeys.
+func (k StaticKeys) Key(id string) ([]byte, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errors.ErrKeyNotFound, id)
+	}
+	return key, nil
+}
+
+// Encryption represents the configuration for encrypting records at rest with
+// AES-GCM.
+type Encryption struct {
+	// Keys provides the encryption keys.
+	Keys KeyProvider
+
+	// Collections limits encryption to the named collections. If empty, every
+	// collection is encrypted.
+	Collections []string
+}
+
+// encrypts reports whether new records in a collection are encrypted.
+func (d *Driver) encrypts(collection string) bool {
+	return d.encryption != nil && inScope(d.encryption.Collections, collection)
+}
+
+// seal encrypts the JSON of a record with the current key.
+func (e *Encryption) seal(plaintext []byte) ([]byte, error) {
+	id, key, err := e.Keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(id) > 255 {
+		return nil, fmt.Errorf("encryption key ID %q is longer than 255 bytes", id)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append(append(append([]byte(nil), sealedMagic...), byte(len(id))), id...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append(header, nonce...)
+	return aead.Seal(out, nonce, plaintext, header), nil
+}
+
+// open decrypts a record produced by seal. The header, including the key ID,
+// is authenticated along with the contents.
+func (e *Encryption) open(sealed []byte) ([]byte, error) {
+	if e == nil || e.Keys == nil {
+		return nil, fmt.Errorf("%w: record is encrypted but no keys are configured", errors.ErrKeyNotFound)
+	}
+
+	if len(sealed) < len(sealedMagic)+1 {
+		return nil, errors.ErrCorruptRecord
+	}
+
+	idLen := int(sealed[len(sealedMagic)])
+	headerLen := len(sealedMagic) + 1 + idLen
+	if len(sealed) < headerLen {
+		return nil, errors.ErrCorruptRecord
+	}
+
+	header := sealed[:headerLen]
+	key, err := e.Keys.Key(string(header[len(sealedMagic)+1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := sealed[headerLen:]
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.ErrCorruptRecord
+	}
+
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+	if err != nil {
+		return nil, errors.ErrCorruptRecord
+	}
+
+	return plaintext, nil
+}
+
+// isSealed reports whether stored bytes hold an encrypted record.
+func isSealed(b []byte) bool {
+	return bytes.HasPrefix(b, sealedMagic)
+}
+
+// newAEAD creates an AES-GCM cipher for the given key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Rekey rewrites every record in a collection with the current encryption
+// settings: records are re-encrypted with the provider's current key, or
+// decrypted if the collection is no longer configured for encryption. Records
+// are also compressed or decompressed to match the current Compression. The
+// previous versions kept by History and the records of the collection waiting
+// in the Trash are rewritten too; damaged files quarantined in the
+// LostAndFound are not. Run it after rotating keys so that retired keys can be
+// removed from the provider.
+func (d *Driver) Rekey(collection string) (err error) {
+	op := d.begin(context.Background(), "rekey", collection, "")
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return errors.ErrClosed
+	}
+
+	if d.readOnly {
+		return errors.ErrReadOnly
+	}
+
+	if collection == "" {
+		return errors.ErrMissingCollection
+	}
+
+	if err := checkPath(collection, ""); err != nil {
+		return err
+	}
+
+	unlock, err := d.lockCollections(op, collection, Trash)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// A collection that was dropped into the Trash may only exist there.
+	n, err := d.rekeyDir(collection, filepath.Join(d.dir, collection))
+	op.bytes += n
+	missing := stderrors.Is(err, errors.ErrNotFound)
+	if err != nil && !missing {
+		return err
+	}
+
+	n, found, err := d.rekeyTrash(collection)
+	op.bytes += n
+	if err != nil {
+		return err
+	}
+
+	if missing && !found {
+		return errors.NewNotFoundError(collection, os.ErrNotExist)
+	}
+
+	return nil
+}
+
+// rekeyDir is a helper function for rewriting the records stored directly in
+// a collection directory, and every version kept in its history, including
+// that of deleted records, with the current settings. Records outside the
+// collection itself, such as in the Trash, are rewritten where they lie. It
+// returns the number of bytes written.
+func (d *Driver) rekeyDir(collection, dir string) (int, error) {
+	total := 0
+	live := dir == filepath.Join(d.dir, collection)
+
+	ids, paths, err := recordFiles(dir)
+	if err != nil {
+		return 0, errors.NewPathError(dir, err)
+	}
+
+	for _, id := range ids {
+		recordDir := dir
+		if !live {
+			recordDir = filepath.Dir(paths[id])
+		}
+
+		n, err := d.rekeyRecord(collection, recordDir, id)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	history := filepath.Join(dir, historyDir)
+	files, err := os.ReadDir(history)
+	if err != nil && !os.IsNotExist(err) {
+		return total, errors.NewFileIOError(history, err)
+	}
+
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+
+		versions := filepath.Join(history, file.Name())
+		revs, err := resources(versions)
+		if err != nil {
+			return total, errors.NewPathError(versions, err)
+		}
+
+		for _, rev := range revs {
+			n, err := d.rekeyRecord(collection, versions, rev)
+			total += n
+			if err != nil {
+				return total, err
+			}
+		}
+	}
+
+	return total, nil
+}
+
+// rekeyTrash is a helper function for rewriting the records of a collection
+// that are waiting in the Trash, whether deleted on their own or along with
+// the collection or one of its parents, so that restoring them never needs a
+// retired key. It returns the number of bytes written and whether the Trash
+// held anything from the collection.
+func (d *Driver) rekeyTrash(collection string) (int, bool, error) {
+	trash := filepath.Join(d.dir, Trash)
+	ids, err := resources(trash)
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, errors.NewFileIOError(trash, err)
+	}
+
+	total, found := 0, false
+
+	for _, id := range ids {
+		entry, err := d.trashEntry(id)
+		if err != nil {
+			return total, found, err
+		}
+
+		root := filepath.Join(trash, id)
+
+		switch {
+		case entry.Resource != "" && entry.Collection == collection:
+			found = true
+			src := filepath.Join(root, filepath.FromSlash(entry.Path))
+			n, err := d.rekeyRecord(collection, filepath.Dir(src), entry.Resource)
+			total += n
+			if err != nil {
+				return total, found, err
+			}
+
+			// The record may now be stored under the other extension.
+			record, err := d.findRecord(collection, filepath.Dir(src), entry.Resource)
+			if err != nil {
+				return total, found, errors.NewPathError(record, err)
+			}
+
+			if record != src {
+				rel, err := filepath.Rel(root, record)
+				if err != nil {
+					return total, found, err
+				}
+
+				entry.Path = filepath.ToSlash(rel)
+				if err := d.saveTrashEntry(id, entry); err != nil {
+					return total, found, err
+				}
+			}
+
+		case entry.Resource == "" && (entry.Collection == collection || strings.HasPrefix(collection, entry.Collection+"/")):
+			dir := filepath.Join(root, filepath.FromSlash(collection))
+			if _, err := os.Stat(dir); os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return total, found, errors.NewFileIOError(dir, err)
+			}
+
+			found = true
+			n, err := d.rekeyDir(collection, dir)
+			total += n
+			if err != nil {
+				return total, found, err
+			}
+		}
+	}
+
+	return total, found, nil
+}
+
+// rekeyRecord is a helper function for rewriting a single record file with
+// the current settings. It returns the number of bytes written.
+func (d *Driver) rekeyRecord(collection, dir, resource string) (int, error) {
+	record, err := d.findRecord(collection, dir, resource)
+	if err != nil {
+		return 0, errors.NewPathError(record, err)
+	}
+
+	b, _, err := d.readRecord(record)
+	if err != nil {
+		return 0, errors.NewPathError(record, err)
+	}
+
+	return d.writeRecord(collection, dir, resource, b)
+}
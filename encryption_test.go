@@ -0,0 +1,210 @@
+package scribble
+
+import (
+	"bytes"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// testKeys returns a key provider holding two keys, with "old" as the current one.
+func testKeys() StaticKeys {
+	return StaticKeys{
+		Current: "old",
+		Keys: map[string][]byte{
+			"old": bytes.Repeat([]byte{1}, 32),
+			"new": bytes.Repeat([]byte{2}, 32),
+		},
+	}
+}
+
+// TestEncryption tests that encrypted records round trip without storing plaintext.
+func TestEncryption(t *testing.T) {
+	d := newTestDriver(t, t.TempDir(), &Options{Encryption: &Encryption{Keys: testKeys()}})
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	b, err := os.ReadFile(filepath.Join(d.dir, collection, "red.json"))
+	if err != nil {
+		t.Fatal("Failed to read record file: ", err.Error())
+	}
+
+	if bytes.Contains(b, []byte("red")) {
+		t.Error("Expected record to be encrypted on disk, got: ", string(b))
+	}
+
+	if err := d.Read(collection, "red", &onefish); err != nil || onefish != redfish {
+		t.Error("Expected redfish, got: ", onefish, err)
+	}
+
+	records, err := d.ReadAll(collection)
+	if err != nil || len(records) != 1 {
+		t.Error("Expected one decrypted record, got: ", len(records), err)
+	}
+}
+
+// TestEncryptionCollections tests that encryption can be limited to some collections.
+func TestEncryptionCollections(t *testing.T) {
+	d := newTestDriver(t, t.TempDir(), &Options{Encryption: &Encryption{Keys: testKeys(), Collections: []string{"secrets"}}})
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	b, err := os.ReadFile(filepath.Join(d.dir, collection, "red.json"))
+	if err != nil || !bytes.Contains(b, []byte("red")) {
+		t.Error("Expected unlisted collection to stay plaintext, got: ", string(b), err)
+	}
+}
+
+// TestRekey tests that rotating keys and rekeying keeps records readable.
+func TestRekey(t *testing.T) {
+	dir := t.TempDir()
+	keys := testKeys()
+
+	if err := newTestDriver(t, dir, &Options{Encryption: &Encryption{Keys: keys}}).Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	keys.Current = "new"
+	d := newTestDriver(t, dir, &Options{Encryption: &Encryption{Keys: keys}})
+
+	if err := d.Read(collection, "red", &onefish); err != nil {
+		t.Error("Expected record under the old key to stay readable, got: ", err)
+	}
+
+	if err := d.Rekey(collection); err != nil {
+		t.Fatal("Rekey failed: ", err.Error())
+	}
+
+	delete(keys.Keys, "old")
+
+	if err := d.Read(collection, "red", &onefish); err != nil || onefish != redfish {
+		t.Error("Expected redfish after retiring the old key, got: ", onefish, err)
+	}
+}
+
+// TestEncryptionErrors tests that missing keys and tampering are reported.
+func TestEncryptionErrors(t *testing.T) {
+	dir := t.TempDir()
+	d := newTestDriver(t, dir, &Options{Encryption: &Encryption{Keys: testKeys()}})
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if _, err := New(dir, nil); !stderrors.Is(err, errors.ErrIncompatible) {
+		t.Error("Expected ErrIncompatible opening without keys, got: ", err)
+	}
+
+	plain, err := New(dir, &Options{Reconfigure: true})
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := plain.Read(collection, "red", &onefish); !stderrors.Is(err, errors.ErrKeyNotFound) {
+		t.Error("Expected ErrKeyNotFound without keys, got: ", err)
+	}
+
+	record := filepath.Join(dir, collection, "red.json")
+	b, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatal("Failed to read record file: ", err.Error())
+	}
+
+	b[len(b)-1] ^= 0xff
+	if err := os.WriteFile(record, b, 0644); err != nil {
+		t.Fatal("Failed to tamper with record: ", err.Error())
+	}
+
+	if err := d.Read(collection, "red", &onefish); !stderrors.Is(err, errors.ErrCorruptRecord) {
+		t.Error("Expected ErrCorruptRecord after tampering, got: ", err)
+	}
+
+	report, err := d.Check(nil)
+	if err != nil || len(report.Issues) != 1 || report.Issues[0].Kind != IssueUnreadable {
+		t.Error("Expected Check to report the unreadable record, got: ", report.Issues, err)
+	}
+}
+
+// TestRekeyTrash tests that rekeying also rewrites the records and history of
+// a collection waiting in the Trash, so they can be restored once the old key
+// is retired.
+func TestRekeyTrash(t *testing.T) {
+	dir := t.TempDir()
+	keys := testKeys()
+	options := func() *Options {
+		return &Options{Encryption: &Encryption{Keys: keys}, History: &History{}, SoftDelete: true}
+	}
+
+	d := newTestDriver(t, dir, options())
+
+	for _, fish := range []Fish{redfish, bluefish} {
+		if err := d.Write(collection, "red", fish); err != nil {
+			t.Fatal("Write fish failed: ", err.Error())
+		}
+	}
+
+	if err := d.Write(collection+"/school", "blue", bluefish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Delete(collection, "red"); err != nil {
+		t.Fatal("Delete fish failed: ", err.Error())
+	}
+
+	if err := d.DropCollection(collection); err != nil {
+		t.Fatal("Drop collection failed: ", err.Error())
+	}
+
+	keys.Current = "new"
+	d = newTestDriver(t, dir, options())
+
+	for _, c := range []string{collection, collection + "/school"} {
+		if err := d.Rekey(c); err != nil {
+			t.Fatal("Rekey failed: ", err.Error())
+		}
+	}
+
+	if err := d.Rekey("whales"); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected ErrNotFound rekeying a missing collection, got: ", err)
+	}
+
+	delete(keys.Keys, "old")
+
+	entries, err := d.ListTrash()
+	if err != nil || len(entries) != 2 {
+		t.Fatal("Expected two entries, got: ", entries, err)
+	}
+
+	// The collection has to be back before the record deleted from it.
+	for _, i := range []int{1, 0} {
+		if err := d.Restore(entries[i].ID); err != nil {
+			t.Fatal("Restore failed: ", err.Error())
+		}
+	}
+
+	if err := d.Read(collection+"/school", "blue", &onefish); err != nil || onefish != bluefish {
+		t.Error("Expected bluefish in the school, got: ", onefish, err)
+	}
+
+	versions, err := d.History(collection, "red")
+	if err != nil || len(versions) != 2 {
+		t.Fatal("Expected two versions, got: ", versions, err)
+	}
+
+	for _, version := range versions {
+		if err := d.ReadVersion(collection, "red", version.Rev, &onefish); err != nil {
+			t.Error("Expected every version to be readable, got: ", err)
+		}
+	}
+
+	if err := d.Read(collection, "red", &onefish); err != nil || onefish != bluefish {
+		t.Error("Expected the deleted fish back, got: ", onefish, err)
+	}
+}
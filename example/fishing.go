@@ -11,13 +11,14 @@ type Fish struct {
 	Name string
 }
 
-// FishingExample is an example demonstrating fishing functionality
+// FishingExample is an example demonstrating fishing functionality. It works
+// against any scribble.Store, local or remote.
 type FishingExample struct {
-	db *scribble.Driver
+	db scribble.Store
 }
 
 // NewFishingExample creates a new FishingExample instance
-func NewFishingExample(db *scribble.Driver) *FishingExample {
+func NewFishingExample(db scribble.Store) *FishingExample {
 	return &FishingExample{db: db}
 }
 
@@ -59,7 +60,7 @@ func (fe *FishingExample) DeleteFishFromDatabase(name string) error {
 
 // DeleteAllFishFromDatabase deletes all fish from the database
 func (fe *FishingExample) DeleteAllFishFromDatabase() error {
-	return fe.db.Delete("fish", "")
+	return fe.db.DropCollection("fish")
 }
 
 // func main() {
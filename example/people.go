@@ -1,6 +1,7 @@
 package example
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -46,20 +47,24 @@ func (pe *PeopleExample) SetupDatabase() error {
 }
 
 // WritePeopleToDatabase writes a slice of Person instances to the database
-func (pe *PeopleExample) WritePeopleToDatabase(people []*Person) {
+// and returns the generated ID of each person, in order
+func (pe *PeopleExample) WritePeopleToDatabase(people []*Person) []string {
+	ids := make([]string, 0, len(people))
 	for _, person := range people {
-		err := pe.db.Write("people", person.Name, person)
+		id, err := pe.db.Insert("people", person)
 		if err != nil {
 			log.Fatal(err)
 		}
-		fmt.Printf("Wrote: %v\n", person.Name)
+		ids = append(ids, id)
+		fmt.Printf("Wrote: %v (%v)\n", person.Name, id)
 	}
+	return ids
 }
 
-// DeletePersonFromDatabase deletes a person from the database by name
-func (pe *PeopleExample) DeletePersonFromDatabase(personName string) {
-	err := pe.db.Delete("people", personName)
-	if os.IsNotExist(err) {
+// DeletePersonFromDatabase deletes a person from the database by ID
+func (pe *PeopleExample) DeletePersonFromDatabase(id string) {
+	err := pe.db.Delete("people", id)
+	if errors.Is(err, os.ErrNotExist) {
 		log.Fatal(err)
 	}
 }
@@ -94,10 +99,10 @@ func (pe *PeopleExample) GenerateFakeUsers(count int) []*Person {
 // 	fakeUsers := peopleExample.GenerateFakeUsers(1000)
 
 // 	// Write fake users to the database
-// 	peopleExample.WritePeopleToDatabase(fakeUsers)
+// 	ids := peopleExample.WritePeopleToDatabase(fakeUsers)
 
 // 	// Delete a fake user from the database (just an example)
-// 	peopleExample.DeletePersonFromDatabase(fakeUsers[0].Name)
+// 	peopleExample.DeletePersonFromDatabase(ids[0])
 
 // 	fmt.Println("Done.")
 // 	fmt.Printf("Time Elapsed: %v\n", time.Since(start))
@@ -0,0 +1,167 @@
+package scribble
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// ImportMode controls what Import does with records that already exist.
+type ImportMode int
+
+const (
+	// ImportUpsert overwrites existing records with the imported data.
+	ImportUpsert ImportMode = iota
+
+	// ImportSkipExisting leaves existing records untouched.
+	ImportSkipExisting
+)
+
+// ImportOptions represents the optional configurations for Import.
+type ImportOptions struct {
+	Mode ImportMode
+}
+
+// exportLine is a single line of an NDJSON export.
+type exportLine struct {
+	ID   string          `json:"id"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Export streams every record in a collection to w as newline-delimited JSON,
+// one {"id": ..., "data": ...} object per line, in ID order. Records are read
+// one at a time, and the collection's read lock is held so the export is a
+// consistent snapshot.
+func (d *Driver) Export(collection string, w io.Writer) (err error) {
+	op := d.begin(context.Background(), "export", collection, "")
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return errors.ErrClosed
+	}
+
+	if collection == "" {
+		return errors.ErrMissingCollection
+	}
+
+	if err := checkPath(collection, ""); err != nil {
+		return err
+	}
+
+	mutex := d.getOrCreateLock(collection)
+	if err := op.rlock(mutex); err != nil {
+		return err
+	}
+	defer mutex.RUnlock()
+
+	dir := filepath.Join(d.dir, collection)
+	ids, err := resources(dir)
+	if err != nil {
+		return errors.NewPathError(dir, err)
+	}
+
+	enc := json.NewEncoder(w)
+	for _, id := range ids {
+		record, err := d.findRecord(collection, dir, id)
+		if err != nil {
+			return errors.NewPathError(record, err)
+		}
+
+		b, _, err := d.readRecord(record)
+		if err != nil {
+			return errors.NewPathError(record, err)
+		}
+
+		if err := enc.Encode(exportLine{ID: id, Data: b}); err != nil {
+			return err
+		}
+		op.bytes += len(b)
+	}
+
+	return nil
+}
+
+// Import reads newline-delimited JSON in the format produced by Export from r
+// and writes each record into the collection, storing its data exactly as it
+// appears in the input. Input is decoded and written one record at a time, so
+// arbitrarily large imports use constant memory. It returns the number of
+// records written.
+func (d *Driver) Import(collection string, r io.Reader, options *ImportOptions) (n int, err error) {
+	op := d.begin(context.Background(), "import", collection, "")
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return 0, errors.ErrClosed
+	}
+
+	if d.readOnly {
+		return 0, errors.ErrReadOnly
+	}
+
+	if collection == "" {
+		return 0, errors.ErrMissingCollection
+	}
+
+	if err := checkPath(collection, ""); err != nil {
+		return 0, err
+	}
+
+	opts := ImportOptions{}
+
+	if options != nil {
+		opts = *options
+	}
+
+	dec := json.NewDecoder(r)
+
+	for line := 1; ; line++ {
+		var l exportLine
+		if err := dec.Decode(&l); err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, fmt.Errorf("%w: line %d: %v", errors.ErrInvalidRecord, line, err)
+		}
+
+		if l.ID == "" || l.Data == nil {
+			return n, fmt.Errorf("%w: line %d: missing id or data", errors.ErrInvalidRecord, line)
+		}
+
+		if err := checkPath(collection, l.ID); err != nil {
+			return n, fmt.Errorf("%w: line %d: %q", err, line, l.ID)
+		}
+
+		written, err := d.importRecord(op, collection, l, opts.Mode)
+		if err != nil {
+			return n, err
+		}
+
+		if written > 0 {
+			n++
+			op.bytes += written
+		}
+	}
+}
+
+// importRecord is a helper function for writing a single imported record,
+// returning the number of bytes written, or zero if it was skipped.
+func (d *Driver) importRecord(op *operation, collection string, l exportLine, mode ImportMode) (int, error) {
+	mutex := d.getOrCreateLock(collection)
+	if err := op.lock(mutex); err != nil {
+		return 0, err
+	}
+	defer mutex.Unlock()
+
+	dir := filepath.Join(d.dir, collection)
+
+	if mode == ImportSkipExisting {
+		if _, err := d.findRecord(collection, dir, l.ID); err == nil {
+			return 0, nil
+		}
+	}
+
+	return d.writeRaw(collection, dir, l.ID, l.Data)
+}
@@ -0,0 +1,89 @@
+package scribble
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestExportAndImport tests that an exported collection imports into another database.
+func TestExportAndImport(t *testing.T) {
+	src, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	for _, f := range []Fish{redfish, bluefish} {
+		if err := src.Write(collection, f.Type, f); err != nil {
+			t.Fatal("Create fish failed: ", err.Error())
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := src.Export(collection, &buf); err != nil {
+		t.Fatal("Export failed: ", err.Error())
+	}
+
+	expected := `{"id":"blue","data":{"type":"blue"}}` + "\n" + `{"id":"red","data":{"type":"red"}}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("Expected export %q, got: %q", expected, buf.String())
+	}
+
+	dst, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	n, err := dst.Import(collection, &buf, nil)
+	if err != nil {
+		t.Fatal("Import failed: ", err.Error())
+	}
+
+	if n != 2 {
+		t.Errorf("Expected 2 records imported, got: %d", n)
+	}
+
+	fish := Fish{}
+	if err := dst.Read(collection, "red", &fish); err != nil || fish.Type != "red" {
+		t.Error("Expected red fish, got: ", fish.Type)
+	}
+}
+
+// TestImportSkipExisting tests that skip-existing mode leaves current records alone.
+func TestImportSkipExisting(t *testing.T) {
+	d, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	in := strings.NewReader(`{"id":"red","data":{"type":"blue"}}` + "\n" + `{"id":"blue","data":{"type":"blue"}}` + "\n")
+	n, err := d.Import(collection, in, &ImportOptions{Mode: ImportSkipExisting})
+	if err != nil {
+		t.Fatal("Import failed: ", err.Error())
+	}
+
+	if n != 1 {
+		t.Errorf("Expected 1 record imported, got: %d", n)
+	}
+
+	fish := Fish{}
+	if err := d.Read(collection, "red", &fish); err != nil || fish.Type != "red" {
+		t.Error("Expected red fish to be kept, got: ", fish.Type)
+	}
+}
+
+// TestImportInvalid tests that malformed input is rejected.
+func TestImportInvalid(t *testing.T) {
+	d, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if _, err := d.Import(collection, strings.NewReader(`{"data":{}}`), nil); err == nil {
+		t.Error("Allowed import of record without an id")
+	}
+}
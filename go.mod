@@ -2,10 +2,7 @@ module github.com/D7682/scribble
 
 go 1.21.5
 
-require (
-	github.com/jcelliott/lumber v0.0.0-20160324203708-dd349441af25
-	github.com/stretchr/testify v1.8.4
-)
+require github.com/stretchr/testify v1.8.4
 
 require (
 	github.com/brianvoe/gofakeit/v6 v6.28.0
@@ -0,0 +1,349 @@
+package scribble
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// historyDir is the directory inside a collection that holds the previous
+// versions of its records, one sub-directory per record. Collection paths that
+// use this name are rejected with ErrInvalidPath.
+const historyDir = "_history"
+
+// History represents the configuration for keeping previous versions of
+// records. Whenever a record is overwritten or deleted, the version being
+// replaced is kept under "<collection>/_history/<id>/".
+type History struct {
+	// MaxVersions is the number of previous versions kept per record. Zero
+	// keeps every version.
+	MaxVersions int
+
+	// MaxAge is how long previous versions are kept after being replaced.
+	// Older versions are hidden from History and ReadVersion straight away,
+	// and removed the next time the record is replaced or by PruneHistory.
+	// Zero keeps versions regardless of age.
+	MaxAge time.Duration
+
+	// Collections limits history to the named collections. If empty, every
+	// collection keeps history.
+	Collections []string
+}
+
+// Version describes a previous version of a record.
+type Version struct {
+	Rev  string    // Rev identifies the version; revisions sort in the order they were replaced
+	Time time.Time // Time is when the version was replaced or deleted
+	Size int64     // Size is the number of bytes the version takes on disk
+}
+
+// keepsHistory reports whether a collection keeps previous versions of records.
+func (d *Driver) keepsHistory(collection string) bool {
+	return d.history != nil && inScope(d.history.Collections, collection)
+}
+
+// archive is a helper function for copying the current version of a record,
+// if there is one, into its history before it is replaced or deleted. Old
+// versions beyond the retention limits are then removed.
+func (d *Driver) archive(collection, dir, resource string) error {
+	if !d.keepsHistory(collection) {
+		return nil
+	}
+
+	record, err := d.findRecord(collection, dir, resource)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.NewFileIOError(record, err)
+	}
+
+	b, err := os.ReadFile(record)
+	if err != nil {
+		return errors.NewFileIOError(record, err)
+	}
+
+	rev, err := newID()
+	if err != nil {
+		return err
+	}
+
+	versions := filepath.Join(dir, historyDir, resource)
+	if err := os.MkdirAll(versions, 0755); err != nil {
+		return errors.NewFileIOError(versions, err)
+	}
+
+	// The version is copied as stored, so it keeps its compression and
+	// encryption and is decoded like any other record.
+	dst := filepath.Join(versions, rev+strings.TrimPrefix(filepath.Base(record), filepath.Base(resource)))
+	if err := writeBytes(versions, dst+".tmp", dst, b); err != nil {
+		return err
+	}
+
+	_, err = d.prune(versions, time.Now())
+	return err
+}
+
+// prune is a helper function for removing the versions in a record's history
+// that fall outside the retention limits. It returns the number of versions
+// removed.
+func (d *Driver) prune(versions string, now time.Time) (int, error) {
+	revs, err := resources(versions)
+	if err != nil {
+		return 0, errors.NewPathError(versions, err)
+	}
+
+	n := 0
+	for i, rev := range revs {
+		if (d.history.MaxVersions > 0 && len(revs)-i > d.history.MaxVersions) || d.expired(rev, now) {
+			stale := []string{filepath.Join(versions, rev+recordExt), filepath.Join(versions, rev+compressedExt)}
+			if err := removeFiles(stale); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// expired is a helper function for reporting whether a version is older than
+// the history's MaxAge allows.
+func (d *Driver) expired(rev string, now time.Time) bool {
+	if d.history == nil || d.history.MaxAge <= 0 {
+		return false
+	}
+
+	t, ok := idTime(rev)
+	return ok && now.Sub(t) > d.history.MaxAge
+}
+
+// PruneHistory removes the previous versions of every record in a collection
+// that fall outside the retention limits, including the history of records
+// that have since been deleted or are never written again, which is otherwise
+// only pruned when a record is replaced. It returns the number of versions
+// removed.
+func (d *Driver) PruneHistory(collection string) (n int, err error) {
+	op := d.begin(context.Background(), "prune_history", collection, "")
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return 0, errors.ErrClosed
+	}
+
+	if d.readOnly {
+		return 0, errors.ErrReadOnly
+	}
+
+	if collection == "" {
+		return 0, errors.ErrMissingCollection
+	}
+
+	if err := checkPath(collection, ""); err != nil {
+		return 0, err
+	}
+
+	if d.history == nil {
+		return 0, nil
+	}
+
+	mutex := d.getOrCreateLock(collection)
+	if err := op.lock(mutex); err != nil {
+		return 0, err
+	}
+	defer mutex.Unlock()
+
+	dir := filepath.Join(d.dir, collection, historyDir)
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, errors.NewFileIOError(dir, err)
+	}
+
+	now := time.Now()
+
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+
+		versions := filepath.Join(dir, file.Name())
+		removed, err := d.prune(versions, now)
+		n += removed
+		if err != nil {
+			return n, err
+		}
+
+		// A history left empty is removed along with its directory.
+		if revs, err := resources(versions); err == nil && len(revs) == 0 {
+			if err := os.Remove(versions); err != nil {
+				return n, errors.NewFileIOError(versions, err)
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// History returns the previous versions of a record, oldest first. Versions
+// older than the history's MaxAge are left out.
+func (d *Driver) History(collection, resource string) (versions []Version, err error) {
+	op := d.begin(context.Background(), "history", collection, resource)
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return nil, errors.ErrClosed
+	}
+
+	if collection == "" {
+		return nil, errors.ErrMissingCollection
+	}
+
+	if resource == "" {
+		return nil, errors.ErrMissingResource
+	}
+
+	if err := checkPath(collection, resource); err != nil {
+		return nil, err
+	}
+
+	mutex := d.getOrCreateLock(collection)
+	if err := op.rlock(mutex); err != nil {
+		return nil, err
+	}
+	defer mutex.RUnlock()
+
+	dir := filepath.Join(d.dir, collection, historyDir, resource)
+	revs, err := resources(dir)
+	if err != nil {
+		return nil, errors.NewPathError(dir, err)
+	}
+
+	now := time.Now()
+
+	for _, rev := range revs {
+		if d.expired(rev, now) {
+			continue
+		}
+
+		record, err := d.findRecord(collection, dir, rev)
+		if err != nil {
+			return nil, errors.NewPathError(record, err)
+		}
+
+		fi, err := os.Stat(record)
+		if err != nil {
+			return nil, errors.NewPathError(record, err)
+		}
+
+		t, _ := idTime(rev)
+		versions = append(versions, Version{Rev: rev, Time: t, Size: fi.Size()})
+	}
+
+	return versions, nil
+}
+
+// ReadVersion reads a previous version of a record, as listed by History. A
+// version older than the history's MaxAge is not found.
+func (d *Driver) ReadVersion(collection, resource, rev string, v interface{}) (err error) {
+	op := d.begin(context.Background(), "read_version", collection, resource)
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return errors.ErrClosed
+	}
+
+	if collection == "" {
+		return errors.ErrMissingCollection
+	}
+
+	if resource == "" {
+		return errors.ErrMissingResource
+	}
+
+	if err := checkPath(collection, resource); err != nil {
+		return err
+	}
+
+	mutex := d.getOrCreateLock(collection)
+	if err := op.rlock(mutex); err != nil {
+		return err
+	}
+	defer mutex.RUnlock()
+
+	b, n, err := d.readVersion(collection, resource, rev)
+	op.bytes = n
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// Revert replaces the current contents of a record with a previous version,
+// byte for byte. The contents being replaced are kept in the history like any
+// other write, so a revert can itself be undone.
+func (d *Driver) Revert(collection, resource, rev string) (err error) {
+	op := d.begin(context.Background(), "revert", collection, resource)
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return errors.ErrClosed
+	}
+
+	if d.readOnly {
+		return errors.ErrReadOnly
+	}
+
+	if collection == "" {
+		return errors.ErrMissingCollection
+	}
+
+	if resource == "" {
+		return errors.ErrMissingResource
+	}
+
+	if err := checkPath(collection, resource); err != nil {
+		return err
+	}
+
+	mutex := d.getOrCreateLock(collection)
+	if err := op.lock(mutex); err != nil {
+		return err
+	}
+	defer mutex.Unlock()
+
+	b, _, err := d.readVersion(collection, resource, rev)
+	if err != nil {
+		return err
+	}
+
+	op.bytes, err = d.writeRaw(collection, filepath.Join(d.dir, collection), resource, b)
+	return err
+}
+
+// readVersion is a helper function for reading the JSON of a previous version
+// of a record. It also returns the number of bytes read.
+func (d *Driver) readVersion(collection, resource, rev string) ([]byte, int, error) {
+	if rev == "" || strings.ContainsAny(rev, `/\`) || d.expired(rev, time.Now()) {
+		return nil, 0, errors.NewNotFoundError(filepath.Join(collection, historyDir, resource, rev), os.ErrNotExist)
+	}
+
+	dir := filepath.Join(d.dir, collection, historyDir, resource)
+	record, err := d.findRecord(collection, dir, rev)
+	if err != nil {
+		return nil, 0, errors.NewPathError(record, err)
+	}
+
+	b, n, err := d.readRecord(record)
+	if err != nil {
+		return nil, n, errors.NewPathError(record, err)
+	}
+
+	return b, n, nil
+}
@@ -0,0 +1,129 @@
+package scribble
+
+import (
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// TestHistory tests that overwritten and deleted versions can be read back and reverted to.
+func TestHistory(t *testing.T) {
+	d := newTestDriver(t, t.TempDir(), &Options{History: &History{}})
+
+	if err := d.Write(collection, "fish", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Write(collection, "fish", bluefish); err != nil {
+		t.Fatal("Update fish failed: ", err.Error())
+	}
+
+	if err := d.Delete(collection, "fish"); err != nil {
+		t.Fatal("Delete fish failed: ", err.Error())
+	}
+
+	versions, err := d.History(collection, "fish")
+	if err != nil || len(versions) != 2 {
+		t.Fatal("Expected two versions, got: ", versions, err)
+	}
+
+	if err := d.ReadVersion(collection, "fish", versions[0].Rev, &onefish); err != nil || onefish != redfish {
+		t.Error("Expected the first version to be redfish, got: ", onefish, err)
+	}
+
+	if err := d.Revert(collection, "fish", versions[1].Rev); err != nil {
+		t.Fatal("Revert failed: ", err.Error())
+	}
+
+	if err := d.Read(collection, "fish", &onefish); err != nil || onefish != bluefish {
+		t.Error("Expected the reverted fish to be bluefish, got: ", onefish, err)
+	}
+
+	if records, err := d.ReadAll(collection); err != nil || len(records) != 1 {
+		t.Error("Expected history to be left out of ReadAll, got: ", len(records), err)
+	}
+
+	if err := d.ReadVersion(collection, "fish", "nope", &onefish); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected ErrNotFound for an unknown version, got: ", err)
+	}
+}
+
+// TestHistoryRetention tests that only the newest versions are kept.
+func TestHistoryRetention(t *testing.T) {
+	d := newTestDriver(t, t.TempDir(), &Options{History: &History{MaxVersions: 2}})
+
+	for i := 0; i < 5; i++ {
+		if err := d.Write(collection, "fish", Fish{Type: string(rune('a' + i))}); err != nil {
+			t.Fatal("Write fish failed: ", err.Error())
+		}
+	}
+
+	versions, err := d.History(collection, "fish")
+	if err != nil || len(versions) != 2 {
+		t.Fatal("Expected two versions, got: ", versions, err)
+	}
+
+	if err := d.ReadVersion(collection, "fish", versions[0].Rev, &onefish); err != nil || onefish.Type != "c" {
+		t.Error("Expected the oldest kept version to be c, got: ", onefish, err)
+	}
+}
+
+// TestHistoryCollections tests that history can be limited to some collections.
+func TestHistoryCollections(t *testing.T) {
+	d := newTestDriver(t, t.TempDir(), &Options{History: &History{Collections: []string{"whales"}}})
+
+	if err := d.Write(collection, "fish", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Write(collection, "fish", bluefish); err != nil {
+		t.Fatal("Update fish failed: ", err.Error())
+	}
+
+	if _, err := d.History(collection, "fish"); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected no history for an unlisted collection, got: ", err)
+	}
+}
+
+// TestHistoryMaxAge tests that expired versions are hidden straight away, even
+// for deleted records, and removed by PruneHistory.
+func TestHistoryMaxAge(t *testing.T) {
+	d := newTestDriver(t, t.TempDir(), &Options{History: &History{MaxAge: 50 * time.Millisecond}})
+
+	if err := d.Write(collection, "fish", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Write(collection, "fish", bluefish); err != nil {
+		t.Fatal("Update fish failed: ", err.Error())
+	}
+
+	if err := d.Delete(collection, "fish"); err != nil {
+		t.Fatal("Delete fish failed: ", err.Error())
+	}
+
+	versions, err := d.History(collection, "fish")
+	if err != nil || len(versions) != 2 {
+		t.Fatal("Expected two versions, got: ", versions, err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if expired, err := d.History(collection, "fish"); err != nil || len(expired) != 0 {
+		t.Error("Expected expired versions to be hidden, got: ", expired, err)
+	}
+
+	if err := d.ReadVersion(collection, "fish", versions[0].Rev, &onefish); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected an expired version to be not found, got: ", err)
+	}
+
+	if n, err := d.PruneHistory(collection); err != nil || n != 2 {
+		t.Error("Expected two versions to be pruned, got: ", n, err)
+	}
+
+	if _, err := d.History(collection, "fish"); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected the pruned history to be gone, got: ", err)
+	}
+}
@@ -0,0 +1,96 @@
+package scribble
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"strings"
+	"sync"
+	"time"
+)
+
+// crockford is the Crockford base32 alphabet used to encode IDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// idGenerator produces ULIDs: 48 bits of millisecond timestamp followed by 80
+// bits of randomness, encoded as 26 Crockford base32 characters. IDs created
+// within the same millisecond increment the random part so they still sort in
+// creation order.
+type idGenerator struct {
+	mutex   sync.Mutex
+	lastMS  uint64
+	lastRnd [10]byte
+}
+
+// ids is the package-wide ID generator shared by all drivers.
+var ids idGenerator
+
+// newID returns a new unique, lexicographically time-sortable ID.
+func newID() (string, error) {
+	return ids.next(time.Now())
+}
+
+// next generates the ID for the given time.
+func (g *idGenerator) next(now time.Time) (string, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	ms := uint64(now.UnixMilli())
+
+	if ms <= g.lastMS {
+		// Same (or earlier, if the clock went backwards) millisecond: keep the
+		// previous timestamp and bump the random part to stay monotonic.
+		ms = g.lastMS
+		for i := len(g.lastRnd) - 1; i >= 0; i-- {
+			g.lastRnd[i]++
+			if g.lastRnd[i] != 0 {
+				break
+			}
+		}
+	} else {
+		if _, err := rand.Read(g.lastRnd[:]); err != nil {
+			return "", err
+		}
+		g.lastMS = ms
+	}
+
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], ms<<16)
+	copy(b[6:], g.lastRnd[:])
+
+	return encodeID(b), nil
+}
+
+// encodeID encodes 128 bits as 26 Crockford base32 characters.
+func encodeID(b [16]byte) string {
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+
+	var out [26]byte
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(out[:])
+}
+
+// idTime returns the creation time encoded in an ID produced by newID,
+// reporting whether id is a well-formed ID.
+func idTime(id string) (time.Time, bool) {
+	if len(id) != 26 {
+		return time.Time{}, false
+	}
+
+	// The first 10 characters hold 50 bits: two zero bits and the timestamp.
+	var ms uint64
+	for _, c := range id[:10] {
+		i := strings.IndexRune(crockford, c)
+		if i < 0 {
+			return time.Time{}, false
+		}
+		ms = ms<<5 | uint64(i)
+	}
+
+	return time.UnixMilli(int64(ms)), true
+}
@@ -0,0 +1,57 @@
+package scribble
+
+import (
+	"testing"
+	"time"
+)
+
+// TestNewIDMonotonic tests that IDs generated within one millisecond still sort in order.
+func TestNewIDMonotonic(t *testing.T) {
+	g := idGenerator{}
+	now := time.Now()
+
+	prev := ""
+	for i := 0; i < 1000; i++ {
+		id, err := g.next(now)
+		if err != nil {
+			t.Fatal("Failed to generate ID: ", err.Error())
+		}
+
+		if len(id) != 26 {
+			t.Errorf("Expected 26 character ID, got: %s", id)
+		}
+
+		if id <= prev {
+			t.Errorf("Expected %s to sort after %s", id, prev)
+		}
+		prev = id
+	}
+}
+
+// TestNewIDTimeOrdered tests that IDs from later milliseconds sort after earlier ones.
+func TestNewIDTimeOrdered(t *testing.T) {
+	g := idGenerator{}
+	now := time.Now()
+
+	first, _ := g.next(now)
+	second, _ := g.next(now.Add(time.Millisecond))
+
+	if first >= second {
+		t.Errorf("Expected %s to sort after %s", second, first)
+	}
+}
+
+// TestIDTime tests that the creation time can be recovered from an ID.
+func TestIDTime(t *testing.T) {
+	g := idGenerator{}
+	now := time.UnixMilli(1700000000123)
+
+	id, _ := g.next(now)
+	if got, ok := idTime(id); !ok || !got.Equal(now) {
+		t.Errorf("Expected %v, got: %v", now, got)
+	}
+
+	if _, ok := idTime("red"); ok {
+		t.Error("Expected malformed ID to be rejected")
+	}
+}
@@ -0,0 +1,67 @@
+package scribble
+
+import (
+	"context"
+	stderrors "errors"
+	"time"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// Instrumentation receives a report of every operation performed by a Driver,
+// so that latency, throughput, errors and lock contention can be exported to a
+// metrics or tracing system. Implementations must be safe for concurrent use.
+type Instrumentation interface {
+	// OperationStarted is called before an operation begins. The returned
+	// context is used for the rest of the operation, which lets tracing
+	// implementations start a span and carry it through to OperationFinished.
+	OperationStarted(ctx context.Context, op, collection, resource string) context.Context
+
+	// OperationFinished is called once an operation has completed, with the
+	// context returned by OperationStarted.
+	OperationFinished(ctx context.Context, event OperationEvent)
+}
+
+// OperationEvent describes a completed operation.
+type OperationEvent struct {
+	Op         string // Op is the operation name, such as "write" or "read_all"
+	Collection string
+	Resource   string
+	Duration   time.Duration // Duration is the total time taken, including LockWait
+	LockWait   time.Duration // LockWait is the time spent waiting for collection locks
+	Bytes      int           // Bytes is the number of record bytes read or written
+	Err        error
+	ErrorKind  string // ErrorKind classifies Err for use as a metric label; see errorKind
+}
+
+// errorKind classifies an error into a short, stable label suitable for
+// counting errors by type. It returns an empty string for a nil error.
+func errorKind(err error) string {
+	var (
+		notFound *errors.NotFoundError
+		fileIO   *errors.FileIOError
+	)
+
+	switch {
+	case err == nil:
+		return ""
+	case stderrors.Is(err, context.Canceled):
+		return "canceled"
+	case stderrors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	case stderrors.As(err, &notFound):
+		return "not_found"
+	case stderrors.As(err, &fileIO):
+		return "file_io"
+	case stderrors.Is(err, errors.ErrMissingCollection), stderrors.Is(err, errors.ErrMissingResource),
+		stderrors.Is(err, errors.ErrInvalidPath), stderrors.Is(err, errors.ErrIsCollection),
+		stderrors.Is(err, errors.ErrInvalidRecord):
+		return "invalid_argument"
+	case stderrors.Is(err, errors.ErrReadOnly):
+		return "read_only"
+	case stderrors.Is(err, errors.ErrClosed):
+		return "closed"
+	default:
+		return "other"
+	}
+}
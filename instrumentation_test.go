@@ -0,0 +1,106 @@
+package scribble
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// spanKey is the context key used by recordingInstrumentation to carry a span.
+type spanKey struct{}
+
+// recordingInstrumentation is an Instrumentation that records every event.
+type recordingInstrumentation struct {
+	mutex  sync.Mutex
+	events []OperationEvent
+	spans  []string
+}
+
+func (r *recordingInstrumentation) OperationStarted(ctx context.Context, op, collection, resource string) context.Context {
+	return context.WithValue(ctx, spanKey{}, op+" "+collection+"/"+resource)
+}
+
+func (r *recordingInstrumentation) OperationFinished(ctx context.Context, event OperationEvent) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.events = append(r.events, event)
+	r.spans = append(r.spans, ctx.Value(spanKey{}).(string))
+}
+
+// TestInstrumentation tests that operations are reported with their measurements.
+func TestInstrumentation(t *testing.T) {
+	inst := &recordingInstrumentation{}
+
+	d, err := New(t.TempDir(), &Options{Instrumentation: inst})
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Read(collection, "blue", &Fish{}); err == nil {
+		t.Fatal("Expected nothing, got fish")
+	}
+
+	if len(inst.events) != 2 {
+		t.Fatal("Expected two events, got: ", len(inst.events))
+	}
+
+	write, read := inst.events[0], inst.events[1]
+
+	if write.Op != "write" || write.Collection != collection || write.Resource != "red" {
+		t.Error("Expected write of the red fish, got: ", write)
+	}
+
+	if write.Bytes == 0 || write.Err != nil || write.ErrorKind != "" {
+		t.Error("Expected successful write with a byte count, got: ", write)
+	}
+
+	if read.Err == nil || read.ErrorKind != "not_found" {
+		t.Error("Expected failed read to be classified, got: ", read.ErrorKind)
+	}
+
+	if inst.spans[0] != "write fish/red" {
+		t.Error("Expected the started context to reach OperationFinished, got: ", inst.spans[0])
+	}
+	if _, err := d.List(collection); err != nil {
+		t.Fatal("List failed: ", err.Error())
+	}
+
+	if _, err := d.Collections(); err != nil {
+		t.Fatal("Collections failed: ", err.Error())
+	}
+
+	if len(inst.events) != 4 || inst.events[2].Op != "list" || inst.events[3].Op != "collections" {
+		t.Error("Expected listings to be reported, got: ", inst.events[2:])
+	}
+}
+
+// TestInstrumentationLockWait tests that time spent waiting for a lock is reported.
+func TestInstrumentationLockWait(t *testing.T) {
+	inst := &recordingInstrumentation{}
+
+	d, err := New(t.TempDir(), &Options{Instrumentation: inst})
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	mutex := d.getOrCreateLock(collection)
+	mutex.Lock()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		mutex.Unlock()
+	}()
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if wait := inst.events[0].LockWait; wait < 20*time.Millisecond || wait > inst.events[0].Duration {
+		t.Error("Expected lock wait of at least 20ms within the duration, got: ", wait)
+	}
+}
@@ -0,0 +1,244 @@
+package scribble
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// shardsDir is the directory inside a collection that holds its shards.
+const shardsDir = "_shards"
+
+// Sharding represents the configuration for spreading the records of large
+// collections over nested directories named by a hash of their IDs, as in
+// "<collection>/_shards/ab/cd/<id>.json", so no single directory grows too
+// large. Reads find records in either layout, so sharding can be switched on
+// or off for a collection at any time; MigrateLayout moves existing records
+// over.
+//
+// The _shards directory name is reserved: a collection path that uses it is
+// rejected with ErrInvalidPath.
+type Sharding struct {
+	// Collections limits sharding to the named collections. If empty, every
+	// collection is sharded.
+	Collections []string
+}
+
+// shards reports whether new records in a collection use the sharded layout.
+func (d *Driver) shards(collection string) bool {
+	return d.sharding != nil && inScope(d.sharding.Collections, collection)
+}
+
+// shardDir is a helper function for returning the directory that holds a
+// record in the sharded layout of a collection directory.
+func shardDir(dir, resource string) string {
+	sum := sha256.Sum256([]byte(resource))
+	prefix := hex.EncodeToString(sum[:2])
+	return filepath.Join(dir, shardsDir, prefix[:2], prefix[2:])
+}
+
+// isShardName reports whether a directory name inside the shards directory
+// is the name of a shard.
+func isShardName(name string) bool {
+	if len(name) != 2 {
+		return false
+	}
+
+	for _, c := range name {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// internalDir reports whether a directory inside a collection belongs to the
+// collection itself, holding shards or history, rather than being a
+// sub-collection.
+func internalDir(name string) bool {
+	return name == historyDir || name == shardsDir
+}
+
+// walkCollection is a helper function for calling fn with the slash-separated
+// path, relative to dir, of every file stored for a collection: its records
+// in either layout and the contents of its history, but nothing from its
+// sub-collections.
+func walkCollection(dir string, fn func(rel string, file fs.DirEntry) error) error {
+	return filepath.WalkDir(dir, func(p string, file fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if p == dir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if file.IsDir() {
+			if top, _, _ := strings.Cut(rel, "/"); !internalDir(top) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		return fn(rel, file)
+	})
+}
+
+// recordFiles is a helper function for listing the files holding the records
+// of a collection directory, in either layout. It returns the IDs of the
+// records in lexical order, and the path of the file holding each.
+func recordFiles(dir string) ([]string, map[string]string, error) {
+	var ids []string
+	paths := map[string]string{}
+
+	err := walkCollection(dir, func(rel string, file fs.DirEntry) error {
+		parts := strings.Split(rel, "/")
+		if len(parts) != 1 && (len(parts) != 4 || parts[0] != shardsDir || !isShardName(parts[1]) || !isShardName(parts[2])) {
+			return nil
+		}
+
+		if !file.Type().IsRegular() {
+			return nil
+		}
+
+		id, ok := recordID(parts[len(parts)-1])
+		if !ok {
+			return nil
+		}
+
+		if _, seen := paths[id]; !seen {
+			ids = append(ids, id)
+			paths[id] = filepath.Join(dir, filepath.FromSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Strings(ids)
+	return ids, paths, nil
+}
+
+// MigrateLayout moves every record in a collection into the layout currently
+// configured for it, sharded or flat. The database stays usable while it
+// runs: the collection is locked for one record at a time, and records are
+// moved with a rename, so they stay readable throughout. It returns the
+// number of records moved.
+func (d *Driver) MigrateLayout(collection string) (n int, err error) {
+	op := d.begin(context.Background(), "migrate_layout", collection, "")
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return 0, errors.ErrClosed
+	}
+
+	if d.readOnly {
+		return 0, errors.ErrReadOnly
+	}
+
+	if collection == "" {
+		return 0, errors.ErrMissingCollection
+	}
+
+	if err := checkPath(collection, ""); err != nil {
+		return 0, err
+	}
+
+	dir := filepath.Join(d.dir, collection)
+	ids, err := resources(dir)
+	if err != nil {
+		return 0, errors.NewPathError(dir, err)
+	}
+
+	mutex := d.getOrCreateLock(collection)
+
+	for _, id := range ids {
+		if err := op.lock(mutex); err != nil {
+			return n, err
+		}
+
+		moved, err := d.migrateRecord(collection, dir, id)
+		mutex.Unlock()
+		if err != nil {
+			return n, err
+		}
+
+		if moved {
+			n++
+		}
+	}
+
+	if !d.shards(collection) {
+		removeShardDirs(dir)
+	}
+
+	return n, nil
+}
+
+// migrateRecord is a helper function for moving a single record into the
+// layout configured for its collection, reporting whether it was moved.
+func (d *Driver) migrateRecord(collection, dir, resource string) (bool, error) {
+	record, err := d.findRecord(collection, dir, resource)
+	if os.IsNotExist(err) {
+		// Deleted since the collection was listed.
+		return false, nil
+	} else if err != nil {
+		return false, errors.NewFileIOError(record, err)
+	}
+
+	dstDir := filepath.Dir(d.recordPaths(collection, dir, resource)[0])
+	if filepath.Dir(record) == dstDir {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return false, errors.NewFileIOError(dstDir, err)
+	}
+
+	dst := filepath.Join(dstDir, filepath.Base(record))
+	if err := os.Rename(record, dst); err != nil {
+		return false, errors.NewFileIOError(record, err)
+	}
+
+	return true, nil
+}
+
+// removeShardDirs is a helper function for removing the shard directories of
+// a collection that have been left empty. Directories that are not empty are
+// left alone.
+func removeShardDirs(dir string) {
+	shards := filepath.Join(dir, shardsDir)
+
+	outer, _ := os.ReadDir(shards)
+	for _, o := range outer {
+		if !o.IsDir() || !isShardName(o.Name()) {
+			continue
+		}
+
+		inner, _ := os.ReadDir(filepath.Join(shards, o.Name()))
+		for _, i := range inner {
+			if i.IsDir() && isShardName(i.Name()) {
+				os.Remove(filepath.Join(shards, o.Name(), i.Name()))
+			}
+		}
+
+		os.Remove(filepath.Join(shards, o.Name()))
+	}
+
+	os.Remove(shards)
+}
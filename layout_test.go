@@ -0,0 +1,148 @@
+package scribble
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSharding tests that sharded records are stored by hash prefix and handled transparently.
+func TestSharding(t *testing.T) {
+	d := newTestDriver(t, t.TempDir(), &Options{Sharding: &Sharding{}})
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Write(collection, "blue", bluefish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	record := filepath.Join(shardDir(filepath.Join(d.dir, collection), "red"), "red.json")
+	if _, err := os.Stat(record); err != nil {
+		t.Fatal("Expected record in its shard, got: ", err)
+	}
+
+	if err := d.Read(collection, "red", &onefish); err != nil || onefish != redfish {
+		t.Error("Expected redfish, got: ", onefish, err)
+	}
+
+	if ids, err := d.List(collection); err != nil || len(ids) != 2 || ids[0] != "blue" {
+		t.Error("Expected both fish in order, got: ", ids, err)
+	}
+
+	if collections, err := d.Collections(); err != nil || len(collections) != 1 {
+		t.Error("Expected shards to be hidden from Collections, got: ", collections, err)
+	}
+
+	if err := d.Delete(collection, "red"); err != nil {
+		t.Fatal("Delete fish failed: ", err.Error())
+	}
+
+	if records, err := d.ReadAll(collection); err != nil || len(records) != 1 {
+		t.Error("Expected one fish left, got: ", len(records), err)
+	}
+}
+
+// TestHexSubCollection tests that sub-collections named like shards are still
+// sub-collections, with or without sharding.
+func TestHexSubCollection(t *testing.T) {
+	dir := t.TempDir()
+
+	d, err := New(dir, nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := d.Write("users/ab", "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	collections, err := d.Collections()
+	if err != nil || len(collections) != 2 || collections[1] != "users/ab" {
+		t.Error("Expected users/ab to be a collection, got: ", collections, err)
+	}
+
+	sharded, err := New(dir, &Options{Sharding: &Sharding{Collections: []string{"users"}}, Reconfigure: true})
+	if err != nil {
+		t.Fatal("Failed to open database: ", err.Error())
+	}
+
+	if ids, err := sharded.List("users"); err != nil || len(ids) != 0 {
+		t.Error("Expected users/ab not to be counted in users, got: ", ids, err)
+	}
+
+	if ids, err := sharded.List("users/ab"); err != nil || len(ids) != 1 {
+		t.Error("Expected the fish in users/ab, got: ", ids, err)
+	}
+}
+
+// TestMigrateLayout tests moving a collection between the flat and sharded layouts.
+func TestMigrateLayout(t *testing.T) {
+	dir := t.TempDir()
+
+	flat := newTestDriver(t, dir, &Options{Sharding: &Sharding{Collections: []string{"whales"}}})
+	for _, fish := range []Fish{redfish, bluefish} {
+		if err := flat.Write(collection, fish.Type, fish); err != nil {
+			t.Fatal("Create fish failed: ", err.Error())
+		}
+	}
+
+	sharded, err := New(dir, &Options{Sharding: &Sharding{}, Reconfigure: true})
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := sharded.Write(collection, "green", Fish{Type: "green"}); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if records, err := sharded.ReadAll(collection); err != nil || len(records) != 3 {
+		t.Error("Expected to read both layouts at once, got: ", len(records), err)
+	}
+
+	if n, err := sharded.MigrateLayout(collection); err != nil || n != 2 {
+		t.Error("Expected two fish to move into shards, got: ", n, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, collection, "red.json")); !os.IsNotExist(err) {
+		t.Error("Expected no flat records left, got: ", err)
+	}
+
+	if n, err := flat.MigrateLayout(collection); err != nil || n != 3 {
+		t.Error("Expected three fish to move back, got: ", n, err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, collection))
+	if err != nil || len(entries) != 3 {
+		t.Error("Expected only flat records, with empty shards removed, got: ", len(entries), err)
+	}
+}
+
+// TestShardingBackup tests that backups and checks include sharded records.
+func TestShardingBackup(t *testing.T) {
+	d := newTestDriver(t, t.TempDir(), &Options{Sharding: &Sharding{}})
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if report, err := d.Check(nil); err != nil || report.Records != 1 {
+		t.Error("Expected Check to see the sharded fish, got: ", report, err)
+	}
+
+	var buf bytes.Buffer
+	if err := d.Backup(&buf); err != nil {
+		t.Fatal("Backup failed: ", err.Error())
+	}
+
+	dir := filepath.Join(t.TempDir(), "restored")
+	if err := Restore(&buf, dir); err != nil {
+		t.Fatal("Restore failed: ", err.Error())
+	}
+
+	if err := newTestDriver(t, dir, &Options{Sharding: &Sharding{}}).Read(collection, "red", &onefish); err != nil || onefish != redfish {
+		t.Error("Expected redfish from the backup, got: ", onefish, err)
+	}
+}
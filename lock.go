@@ -0,0 +1,133 @@
+package scribble
+
+import (
+	"context"
+	"sync"
+)
+
+// rwLock is a reader/writer lock for a collection. Unlike sync.RWMutex,
+// waiting for it can be abandoned when a context is cancelled. Like
+// sync.RWMutex, a writer waiting for the lock keeps new readers out, so a
+// steady stream of readers cannot starve writers; a reader must therefore
+// never take the lock it already holds. A nil *rwLock is a lock that is never
+// held: acquiring it only checks the context.
+type rwLock struct {
+	mutex   sync.Mutex
+	readers int
+	writer  bool
+	waiting int           // waiting counts the writers waiting for the lock
+	wake    chan struct{} // wake is closed and replaced each time the lock is released
+}
+
+// newRWLock creates a new, unlocked rwLock.
+func newRWLock() *rwLock {
+	return &rwLock{wake: make(chan struct{})}
+}
+
+// Lock acquires the lock exclusively, waiting as long as necessary.
+func (l *rwLock) Lock() {
+	l.acquire(context.Background(), true)
+}
+
+// RLock acquires the lock shared, waiting as long as necessary.
+func (l *rwLock) RLock() {
+	l.acquire(context.Background(), false)
+}
+
+// LockContext acquires the lock exclusively, or returns the context's error
+// if it is done first.
+func (l *rwLock) LockContext(ctx context.Context) error {
+	return l.acquire(ctx, true)
+}
+
+// RLockContext acquires the lock shared, or returns the context's error if it
+// is done first.
+func (l *rwLock) RLockContext(ctx context.Context) error {
+	return l.acquire(ctx, false)
+}
+
+// acquire is a helper function for waiting on the lock.
+func (l *rwLock) acquire(ctx context.Context, exclusive bool) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	waiting := false
+	defer func() {
+		if waiting {
+			// The writer gave up, so readers it was keeping out may proceed.
+			l.mutex.Lock()
+			l.waiting--
+			l.release()
+			l.mutex.Unlock()
+		}
+	}()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		l.mutex.Lock()
+		if exclusive && !l.writer && l.readers == 0 {
+			l.writer = true
+			if waiting {
+				l.waiting--
+				waiting = false
+			}
+			l.mutex.Unlock()
+			return nil
+		}
+		if !exclusive && !l.writer && l.waiting == 0 {
+			l.readers++
+			l.mutex.Unlock()
+			return nil
+		}
+		if exclusive && !waiting {
+			l.waiting++
+			waiting = true
+		}
+		wake := l.wake
+		l.mutex.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Unlock releases an exclusive hold on the lock.
+func (l *rwLock) Unlock() {
+	if l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.writer = false
+	l.release()
+}
+
+// RUnlock releases a shared hold on the lock.
+func (l *rwLock) RUnlock() {
+	if l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.readers--
+	if l.readers == 0 {
+		l.release()
+	}
+}
+
+// release is a helper function for waking every waiter.
+func (l *rwLock) release() {
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
@@ -0,0 +1,149 @@
+package scribble
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRWLockExclusive tests that writers exclude both readers and writers.
+func TestRWLockExclusive(t *testing.T) {
+	l := newRWLock()
+	l.Lock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := l.RLockContext(ctx); !stderrors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected reader to time out, got: ", err)
+	}
+
+	if err := l.LockContext(ctx); !stderrors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected writer to time out, got: ", err)
+	}
+
+	l.Unlock()
+
+	if err := l.LockContext(context.Background()); err != nil {
+		t.Error("Expected lock after release, got: ", err)
+	}
+}
+
+// TestRWLockShared tests that readers share the lock and wake waiting writers.
+func TestRWLockShared(t *testing.T) {
+	l := newRWLock()
+	l.RLock()
+	l.RLock()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		l.Lock()
+		l.Unlock()
+	}()
+
+	l.RUnlock()
+	l.RUnlock()
+	wg.Wait()
+}
+
+// TestRWLockWriterPriority tests that a waiting writer keeps new readers out,
+// and lets them in again if it gives up.
+func TestRWLockWriterPriority(t *testing.T) {
+	l := newRWLock()
+	l.RLock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	locked := make(chan error)
+	go func() { locked <- l.LockContext(ctx) }()
+
+	// Wait for the writer to start waiting.
+	for {
+		l.mutex.Lock()
+		waiting := l.waiting
+		l.mutex.Unlock()
+		if waiting == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	short, cancelShort := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelShort()
+
+	if err := l.RLockContext(short); !stderrors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected reader to wait behind the writer, got: ", err)
+	}
+
+	cancel()
+	if err := <-locked; !stderrors.Is(err, context.Canceled) {
+		t.Fatal("Expected writer to give up, got: ", err)
+	}
+
+	if err := l.RLockContext(context.Background()); err != nil {
+		t.Error("Expected reader once the writer gave up, got: ", err)
+	}
+	l.RUnlock()
+	l.RUnlock()
+
+	if err := l.LockContext(context.Background()); err != nil {
+		t.Error("Expected lock after release, got: ", err)
+	}
+}
+
+// TestContextCancelled tests that the context variants stop once ctx is done.
+func TestContextCancelled(t *testing.T) {
+	d, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := d.ReadContext(ctx, collection, "red", &Fish{}); !stderrors.Is(err, context.Canceled) {
+		t.Error("Expected read to be cancelled, got: ", err)
+	}
+
+	if _, err := d.ReadAllContext(ctx, collection); !stderrors.Is(err, context.Canceled) {
+		t.Error("Expected read all to be cancelled, got: ", err)
+	}
+
+	if err := d.DeleteContext(ctx, collection, "red"); !stderrors.Is(err, context.Canceled) {
+		t.Error("Expected delete to be cancelled, got: ", err)
+	}
+
+	if err := d.Read(collection, "red", &Fish{}); err != nil {
+		t.Error("Expected cancelled delete to leave the fish, got: ", err)
+	}
+}
+
+// TestContextLockWait tests that waiting for a busy collection lock honors deadlines.
+func TestContextLockWait(t *testing.T) {
+	d, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	mutex := d.getOrCreateLock(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := d.WriteContext(ctx, collection, "red", redfish); !stderrors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected write to time out waiting for the lock, got: ", err)
+	}
+
+	if err := d.UpdateContext(ctx, collection, "red", nil); !stderrors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected update to time out waiting for the lock, got: ", err)
+	}
+}
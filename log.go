@@ -0,0 +1,76 @@
+package scribble
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// discardHandler is a slog.Handler that drops every record. It is the
+// default, so the driver is silent unless a logger is configured.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// loggerHandler is a slog.Handler that forwards records to a printf-style
+// Logger, rendering attributes as key=value pairs after the message.
+type loggerHandler struct {
+	log    Logger
+	prefix string // prefix is the dotted group name applied to new attributes
+	attrs  []string
+}
+
+// Enabled implements slog.Handler; filtering is left to the Logger.
+func (h *loggerHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+// Handle implements slog.Handler by formatting the record and passing it to
+// the Logger method matching its level.
+func (h *loggerHandler) Handle(_ context.Context, r slog.Record) error {
+	parts := append([]string{r.Message}, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		parts = append(parts, h.format(a))
+		return true
+	})
+	msg := strings.Join(parts, " ")
+
+	switch {
+	case r.Level >= slog.LevelError:
+		h.log.Error("%s", msg)
+	case r.Level >= slog.LevelWarn:
+		h.log.Warn("%s", msg)
+	case r.Level >= slog.LevelInfo:
+		h.log.Info("%s", msg)
+	default:
+		h.log.Debug("%s", msg)
+	}
+
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *loggerHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append([]string(nil), h.attrs...)
+	for _, a := range attrs {
+		clone.attrs = append(clone.attrs, h.format(a))
+	}
+	return &clone
+}
+
+// WithGroup implements slog.Handler.
+func (h *loggerHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
+
+// format renders a single attribute as key=value.
+func (h *loggerHandler) format(a slog.Attr) string {
+	return fmt.Sprintf("%s%s=%v", h.prefix, a.Key, a.Value.Resolve())
+}
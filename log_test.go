@@ -0,0 +1,92 @@
+package scribble
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// TestSlogAttributes tests that operations are logged with structured attributes.
+func TestSlogAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	d, err := New(t.TempDir(), &Options{Slog: log})
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+	buf.Reset()
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal("Expected one JSON log line, got: ", buf.String())
+	}
+
+	expected := map[string]interface{}{
+		"msg":        "write",
+		"level":      "DEBUG",
+		"collection": collection,
+		"resource":   "red",
+		"bytes":      float64(len("{\n\t\"type\": \"red\"\n}\n")),
+	}
+	for k, v := range expected {
+		if entry[k] != v {
+			t.Errorf("Expected %s=%v, got: %v", k, v, entry[k])
+		}
+	}
+
+	if _, ok := entry["duration"]; !ok {
+		t.Error("Expected a duration attribute")
+	}
+
+	buf.Reset()
+	if err := d.Read(collection, "blue", &Fish{}); err == nil {
+		t.Fatal("Expected nothing, got fish")
+	}
+
+	if !strings.Contains(buf.String(), `"level":"WARN"`) || !strings.Contains(buf.String(), `"error":`) {
+		t.Error("Expected failed read to be logged as a warning, got: ", buf.String())
+	}
+}
+
+// recordingLogger is a printf-style Logger that records every message.
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) record(level, format string, v ...interface{}) {
+	l.lines = append(l.lines, level+" "+fmt.Sprintf(format, v...))
+}
+
+func (l *recordingLogger) Fatal(f string, v ...interface{}) { l.record("FATAL", f, v...) }
+func (l *recordingLogger) Error(f string, v ...interface{}) { l.record("ERROR", f, v...) }
+func (l *recordingLogger) Warn(f string, v ...interface{})  { l.record("WARN", f, v...) }
+func (l *recordingLogger) Info(f string, v ...interface{})  { l.record("INFO", f, v...) }
+func (l *recordingLogger) Debug(f string, v ...interface{}) { l.record("DEBUG", f, v...) }
+func (l *recordingLogger) Trace(f string, v ...interface{}) { l.record("TRACE", f, v...) }
+
+// TestLegacyLogger tests that a printf-style Logger still receives operation logs.
+func TestLegacyLogger(t *testing.T) {
+	l := &recordingLogger{}
+
+	d, err := New(t.TempDir(), &Options{Logger: l})
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+	l.lines = nil
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if len(l.lines) != 1 || !strings.HasPrefix(l.lines[0], "DEBUG write collection=fish resource=red duration=") {
+		t.Error("Expected one debug line for the write, got: ", l.lines)
+	}
+}
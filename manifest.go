@@ -0,0 +1,206 @@
+package scribble
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// ManifestFile is the name of the file, at the root of every database, that
+// records how the database stores its records.
+const ManifestFile = "scribble.meta.json"
+
+// FormatVersion is the version of the on-disk format written by this package.
+// Databases with a newer format version are refused.
+const FormatVersion = 1
+
+// The modes a Setting can have.
+const (
+	codecJSON = "json"
+
+	layoutFlat    = "flat"
+	layoutSharded = "sharded"
+
+	compressionNone = "none"
+	compressionGzip = "gzip"
+
+	encryptionNone   = "none"
+	encryptionAESGCM = "aes-gcm"
+)
+
+// Manifest describes how a database stores its records. It is written to
+// ManifestFile when a database is created, or when the first record is written
+// to an existing directory that has none, and checked whenever the database
+// is opened.
+type Manifest struct {
+	FormatVersion int       `json:"format_version"`
+	Codec         string    `json:"codec"`       // Codec is the encoding of records; always "json"
+	Layout        Setting   `json:"layout"`      // Layout is "flat" or "sharded"
+	Compression   Setting   `json:"compression"` // Compression is "none" or "gzip"
+	Encryption    Setting   `json:"encryption"`  // Encryption is "none" or "aes-gcm"
+	Created       time.Time `json:"created"`
+}
+
+// Setting is a storage setting and the collections it is limited to, if any.
+type Setting struct {
+	Mode        string   `json:"mode"`
+	Collections []string `json:"collections,omitempty"`
+}
+
+// String returns the mode of the setting, followed by its collections.
+func (s Setting) String() string {
+	if len(s.Collections) == 0 {
+		return s.Mode
+	}
+	return s.Mode + " (" + strings.Join(s.Collections, ", ") + ")"
+}
+
+// setting is a helper function for creating a Setting for a mode limited to
+// the given collections, in a canonical order.
+func setting(mode string, collections []string) Setting {
+	s := Setting{Mode: mode}
+	if len(collections) > 0 {
+		s.Collections = append([]string(nil), collections...)
+		sort.Strings(s.Collections)
+	}
+	return s
+}
+
+// Manifest returns the manifest of the database.
+func (d *Driver) Manifest() Manifest {
+	return d.manifest
+}
+
+// describe is a helper function for building the manifest matching the
+// driver's current settings.
+func (d *Driver) describe(created time.Time) Manifest {
+	m := Manifest{
+		FormatVersion: FormatVersion,
+		Codec:         codecJSON,
+		Layout:        setting(layoutFlat, nil),
+		Compression:   setting(compressionNone, nil),
+		Encryption:    setting(encryptionNone, nil),
+		Created:       created.UTC(),
+	}
+
+	if d.sharding != nil {
+		m.Layout = setting(layoutSharded, d.sharding.Collections)
+	}
+
+	if d.compression != nil {
+		m.Compression = setting(compressionGzip, d.compression.Collections)
+	}
+
+	if d.encryption != nil {
+		m.Encryption = setting(encryptionAESGCM, d.encryption.Collections)
+	}
+
+	return m
+}
+
+// openManifest is a helper function for reconciling the driver's settings
+// with the manifest of the database. If there is none yet, it is written now
+// when New has just created the database, and otherwise by the first write of
+// a record, so merely opening a directory never modifies it.
+// Settings left unset in the options are taken from the manifest; settings
+// that conflict with it are refused with ErrIncompatible unless reconfigure
+// is set, in which case the manifest is updated to match.
+func (d *Driver) openManifest(reconfigure, created bool) error {
+	path := filepath.Join(d.dir, ManifestFile)
+
+	if reconfigure && d.readOnly {
+		return errors.ErrReadOnly
+	}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		d.manifest = d.describe(time.Now())
+		if created {
+			return d.writeManifest()
+		}
+		d.manifestPending = !d.readOnly
+		return nil
+	} else if err != nil {
+		return errors.NewFileIOError(path, err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return fmt.Errorf("%w: unreadable %s: %v", errors.ErrIncompatible, ManifestFile, err)
+	}
+
+	if m.FormatVersion > FormatVersion {
+		return fmt.Errorf("%w: format version %d is newer than the supported %d", errors.ErrIncompatible, m.FormatVersion, FormatVersion)
+	}
+
+	if m.Codec != codecJSON {
+		return fmt.Errorf("%w: unsupported codec %q", errors.ErrIncompatible, m.Codec)
+	}
+
+	if reconfigure {
+		d.manifest = d.describe(m.Created)
+		return d.writeManifest()
+	}
+
+	if d.sharding == nil && m.Layout.Mode == layoutSharded {
+		d.sharding = &Sharding{Collections: m.Layout.Collections}
+	}
+
+	if d.compression == nil && m.Compression.Mode == compressionGzip {
+		d.compression = &Compression{Collections: m.Compression.Collections}
+	}
+
+	want := d.describe(m.Created)
+
+	for _, s := range []struct {
+		name      string
+		have, got Setting
+	}{
+		{"layout", m.Layout, want.Layout},
+		{"compression", m.Compression, want.Compression},
+		{"encryption", m.Encryption, want.Encryption},
+	} {
+		if s.have.String() != s.got.String() {
+			return fmt.Errorf("%w: database %s is %s, but the options ask for %s", errors.ErrIncompatible, s.name, s.have, s.got)
+		}
+	}
+
+	d.manifest = m
+	return nil
+}
+
+// saveManifest is a helper function for writing the manifest of a database
+// opened without one, before its first record is written.
+func (d *Driver) saveManifest() error {
+	d.manifestMutex.Lock()
+	defer d.manifestMutex.Unlock()
+
+	if !d.manifestPending {
+		return nil
+	}
+
+	if err := d.writeManifest(); err != nil {
+		return err
+	}
+
+	d.manifestPending = false
+	return nil
+}
+
+// writeManifest is a helper function for writing the driver's manifest to
+// the database.
+func (d *Driver) writeManifest() error {
+	b, err := json.MarshalIndent(d.manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(d.dir, ManifestFile)
+	return writeBytes(d.dir, path+".tmp", path, append(b, '\n'))
+}
@@ -0,0 +1,109 @@
+package scribble
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// TestManifest tests that the manifest records the settings a database was created with.
+func TestManifest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+
+	if _, err := New(dir, &Options{Compression: &Compression{Collections: []string{"whales", collection}}}); err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		t.Fatal("Expected a manifest, got: ", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal("Failed to parse manifest: ", err.Error())
+	}
+
+	if m.FormatVersion != FormatVersion || m.Codec != "json" || m.Created.IsZero() {
+		t.Error("Expected format, codec and creation time, got: ", m)
+	}
+
+	if m.Layout.String() != "flat" || m.Compression.String() != "gzip (fish, whales)" || m.Encryption.String() != "none" {
+		t.Error("Expected the configured settings, got: ", m.Layout, m.Compression, m.Encryption)
+	}
+}
+
+// TestManifestOpen tests that settings are taken from the manifest and conflicts are refused.
+func TestManifestOpen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+
+	if _, err := New(dir, &Options{Compression: &Compression{}}); err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	d, err := New(dir, nil)
+	if err != nil {
+		t.Fatal("Failed to open database: ", err.Error())
+	}
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, collection, "red.json.gz")); err != nil {
+		t.Error("Expected compression to be taken from the manifest, got: ", err)
+	}
+
+	if _, err := New(dir, &Options{Sharding: &Sharding{}}); !stderrors.Is(err, errors.ErrIncompatible) {
+		t.Error("Expected ErrIncompatible for a different layout, got: ", err)
+	}
+
+	if _, err := New(dir, &Options{Sharding: &Sharding{}, Reconfigure: true}); err != nil {
+		t.Fatal("Failed to reconfigure database: ", err.Error())
+	}
+
+	if d, err := New(dir, nil); err != nil || d.Manifest().Layout.Mode != "sharded" || d.Manifest().Compression.Mode != "none" {
+		t.Error("Expected the new settings to be recorded, got: ", d, err)
+	}
+}
+
+// TestManifestExisting tests that opening an existing directory leaves it
+// untouched until the first record is written.
+func TestManifestExisting(t *testing.T) {
+	dir := t.TempDir()
+
+	d, err := New(dir, &Options{Compression: &Compression{}})
+	if err != nil {
+		t.Fatal("Failed to open database: ", err.Error())
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ManifestFile)); !os.IsNotExist(err) {
+		t.Error("Expected no manifest before the first write, got: ", err)
+	}
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if d, err := New(dir, nil); err != nil || d.Manifest().Compression.Mode != "gzip" {
+		t.Error("Expected the manifest to be written with the first record, got: ", d, err)
+	}
+}
+
+// TestManifestVersion tests that databases written by a newer version are refused.
+func TestManifestVersion(t *testing.T) {
+	dir := t.TempDir()
+
+	manifest := `{"format_version": 99, "codec": "json"}`
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), []byte(manifest), 0644); err != nil {
+		t.Fatal("Failed to write manifest: ", err.Error())
+	}
+
+	if _, err := New(dir, nil); !stderrors.Is(err, errors.ErrIncompatible) {
+		t.Error("Expected ErrIncompatible for a newer format, got: ", err)
+	}
+}
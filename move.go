@@ -0,0 +1,321 @@
+package scribble
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// Move moves a record to a new ID, in the same or another collection,
+// replacing any record already stored there. Both collections are locked for
+// the duration. When they store records alike the file is renamed into place,
+// so the record is never lost or left in both places. Otherwise the record is
+// written to its new place before the original is removed, and a crash in
+// between leaves it in both.
+func (d *Driver) Move(srcCollection, srcResource, dstCollection, dstResource string) (err error) {
+	op := d.begin(context.Background(), "move", srcCollection, srcResource)
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return errors.ErrClosed
+	}
+
+	if d.readOnly {
+		return errors.ErrReadOnly
+	}
+
+	return d.transfer(op, srcCollection, srcResource, dstCollection, dstResource, true)
+}
+
+// Copy copies a record to a new ID, in the same or another collection,
+// replacing any record already stored there. Both collections are locked for
+// the duration, and when they store records alike the stored bytes are copied
+// without being decoded.
+func (d *Driver) Copy(srcCollection, srcResource, dstCollection, dstResource string) (err error) {
+	op := d.begin(context.Background(), "copy", srcCollection, srcResource)
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return errors.ErrClosed
+	}
+
+	if d.readOnly {
+		return errors.ErrReadOnly
+	}
+
+	return d.transfer(op, srcCollection, srcResource, dstCollection, dstResource, false)
+}
+
+// transfer is a helper function for copying a record to a new location, and
+// removing the original if move is set.
+func (d *Driver) transfer(op *operation, srcCollection, srcResource, dstCollection, dstResource string, move bool) error {
+	if srcCollection == "" || dstCollection == "" {
+		return errors.ErrMissingCollection
+	}
+
+	if srcResource == "" || dstResource == "" {
+		return errors.ErrMissingResource
+	}
+
+	if err := checkPath(srcCollection, srcResource); err != nil {
+		return err
+	}
+
+	if err := checkPath(dstCollection, dstResource); err != nil {
+		return err
+	}
+
+	unlock, err := d.lockCollections(op, srcCollection, dstCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	srcDir := filepath.Join(d.dir, srcCollection)
+	src, err := d.findRecord(srcCollection, srcDir, srcResource)
+	if os.IsNotExist(err) {
+		return errors.NewNotFoundError(filepath.Join(srcCollection, srcResource), os.ErrNotExist)
+	} else if err != nil {
+		return errors.NewFileIOError(src, err)
+	}
+
+	dstDir := filepath.Join(d.dir, dstCollection)
+	if srcDir == dstDir && srcResource == dstResource {
+		return nil
+	}
+
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return errors.NewFileIOError(dstDir, err)
+	}
+
+	if err := d.archive(dstCollection, dstDir, dstResource); err != nil {
+		return err
+	}
+
+	// A moved record leaves its history behind, as if it had been deleted.
+	if move {
+		if err := d.archive(srcCollection, srcDir, srcResource); err != nil {
+			return err
+		}
+	}
+
+	paths := d.recordPaths(dstCollection, dstDir, dstResource)
+	dst := paths[0]
+
+	ext, _ := d.recordExts(dstCollection)
+	if d.compresses(srcCollection) != d.compresses(dstCollection) || d.encrypts(srcCollection) != d.encrypts(dstCollection) ||
+		!strings.HasSuffix(src, ext) {
+		// The collections store records differently, so the record has to be
+		// decoded and encoded again.
+		b, n, err := d.readRecord(src)
+		op.bytes = n
+		if err != nil {
+			return errors.NewPathError(src, err)
+		}
+
+		if _, err := d.writeRecord(dstCollection, dstDir, dstResource, b); err != nil {
+			return err
+		}
+	} else {
+		if dir := filepath.Dir(dst); dir != dstDir {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return errors.NewFileIOError(dir, err)
+			}
+		}
+
+		if move {
+			if err := os.Rename(src, dst); err != nil {
+				return errors.NewFileIOError(dst, err)
+			}
+		} else {
+			b, err := os.ReadFile(src)
+			if err != nil {
+				return errors.NewFileIOError(src, err)
+			}
+			op.bytes = len(b)
+
+			if err := writeBytes(dstDir, dst+".tmp", dst, b); err != nil {
+				return err
+			}
+		}
+
+		if err := removeFiles(paths[1:]); err != nil {
+			return err
+		}
+	}
+
+	if move {
+		return d.removeRecord(srcCollection, srcDir, srcResource)
+	}
+
+	return nil
+}
+
+// RenameCollection renames a collection, along with its history and any
+// sub-collections, with a single rename. The collection and every one of its
+// sub-collections are locked while it is renamed. It fails with ErrExists if
+// the new collection already exists. Records keep the compression and
+// encryption they were stored with; Rekey converts them if the new collection
+// is configured differently.
+func (d *Driver) RenameCollection(oldCollection, newCollection string) (err error) {
+	op := d.begin(context.Background(), "rename_collection", oldCollection, "")
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return errors.ErrClosed
+	}
+
+	if d.readOnly {
+		return errors.ErrReadOnly
+	}
+
+	if oldCollection == "" || newCollection == "" {
+		return errors.ErrMissingCollection
+	}
+
+	if err := checkPath(oldCollection, ""); err != nil {
+		return err
+	}
+
+	if err := checkPath(newCollection, ""); err != nil {
+		return err
+	}
+
+	oldDir := filepath.Join(d.dir, oldCollection)
+	newDir := filepath.Join(d.dir, newCollection)
+
+	// A collection cannot be moved inside itself.
+	if strings.HasPrefix(newDir+string(filepath.Separator), oldDir+string(filepath.Separator)) {
+		return errors.ErrInvalidPath
+	}
+
+	unlock, err := d.lockCollectionTree(op, oldCollection, newCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if fi, err := os.Stat(oldDir); err != nil || !fi.IsDir() {
+		return errors.NewNotFoundError(oldCollection, os.ErrNotExist)
+	}
+
+	if _, err := os.Stat(newDir); err == nil {
+		return errors.ErrExists
+	} else if !os.IsNotExist(err) {
+		return errors.NewFileIOError(newDir, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newDir), 0755); err != nil {
+		return errors.NewFileIOError(filepath.Dir(newDir), err)
+	}
+
+	if err := os.Rename(oldDir, newDir); err != nil {
+		return errors.NewFileIOError(newDir, err)
+	}
+
+	return nil
+}
+
+// subCollections is a helper function for listing the sub-collections, at any
+// depth, of the collection stored in dir, in lexical order. A missing
+// collection has none.
+func subCollections(dir, collection string) ([]string, error) {
+	var subs []string
+
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && p == dir {
+			return filepath.SkipDir
+		} else if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() || p == dir {
+			return nil
+		}
+
+		if internalDir(entry.Name()) {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		subs = append(subs, path.Join(collection, filepath.ToSlash(rel)))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.NewFileIOError(dir, err)
+	}
+
+	return subs, nil
+}
+
+// lockCollectionTree is a helper function for locking a collection, every one
+// of its sub-collections and any other given collections exclusively on
+// behalf of an operation, like lockCollections does. It returns a function
+// that releases them all.
+func (d *Driver) lockCollectionTree(op *operation, collection string, others ...string) (func(), error) {
+	dir := filepath.Join(d.dir, collection)
+
+	// Sub-collections created while the locks are being taken would not be
+	// locked, so list them again once they are held and retry if they differ.
+	for {
+		subs, err := subCollections(dir, collection)
+		if err != nil {
+			return nil, err
+		}
+
+		unlock, err := d.lockCollections(op, append(append(subs, collection), others...)...)
+		if err != nil {
+			return nil, err
+		}
+
+		locked, err := subCollections(dir, collection)
+		if err != nil {
+			unlock()
+			return nil, err
+		}
+
+		if slices.Equal(subs, locked) {
+			return unlock, nil
+		}
+		unlock()
+	}
+}
+
+// lockCollections is a helper function for locking collections exclusively
+// on behalf of an operation. The locks are always taken in the order of the
+// collection names, so two operations locking overlapping collections cannot
+// deadlock. It returns a function that releases them all.
+func (d *Driver) lockCollections(op *operation, collections ...string) (func(), error) {
+	collections = append([]string(nil), collections...)
+	sort.Strings(collections)
+	collections = slices.Compact(collections)
+
+	var locks []*rwLock
+	unlock := func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
+		}
+	}
+
+	for _, collection := range collections {
+		mutex := d.getOrCreateLock(collection)
+		if err := op.lock(mutex); err != nil {
+			unlock()
+			return nil, err
+		}
+		locks = append(locks, mutex)
+	}
+
+	return unlock, nil
+}
@@ -0,0 +1,222 @@
+package scribble
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// TestMove tests moving records within and between collections.
+func TestMove(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{Compression: &Compression{Collections: []string{"archive"}}})
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Move(collection, "red", collection, "scarlet"); err != nil {
+		t.Fatal("Move failed: ", err.Error())
+	}
+
+	if err := d.Read(collection, "red", &onefish); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected the original to be gone, got: ", err)
+	}
+
+	if err := d.Read(collection, "scarlet", &onefish); err != nil || onefish != redfish {
+		t.Error("Expected redfish, got: ", onefish, err)
+	}
+
+	// The archive collection is compressed, so the record is encoded again.
+	if err := d.Move(collection, "scarlet", "archive", "red"); err != nil {
+		t.Fatal("Move between collections failed: ", err.Error())
+	}
+
+	if _, err := os.Stat(filepath.Join(d.dir, "archive", "red"+compressedExt)); err != nil {
+		t.Error("Expected a compressed record, got: ", err)
+	}
+
+	if err := d.Read("archive", "red", &onefish); err != nil || onefish != redfish {
+		t.Error("Expected redfish, got: ", onefish, err)
+	}
+
+	if err := d.Move(collection, "missing", collection, "red"); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected ErrNotFound moving a missing record, got: ", err)
+	}
+
+	if err := d.Move(collection, "red", "../escape", "red"); !stderrors.Is(err, errors.ErrInvalidPath) {
+		t.Error("Expected ErrInvalidPath, got: ", err)
+	}
+}
+
+// TestMoveConcurrent tests that moves in opposite directions between two
+// collections do not deadlock.
+func TestMoveConcurrent(t *testing.T) {
+	d, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := d.Write("left", "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Write("right", "blue", bluefish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	var wg sync.WaitGroup
+	for _, dirs := range [][2]string{{"left", "right"}, {"right", "left"}} {
+		wg.Add(1)
+		go func(src, dst string) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				d.Copy(src, "red", dst, "red")
+				d.Copy(dst, "blue", src, "blue")
+			}
+		}(dirs[0], dirs[1])
+	}
+	wg.Wait()
+
+	if err := d.Read("right", "red", &onefish); err != nil || onefish != redfish {
+		t.Error("Expected redfish, got: ", onefish, err)
+	}
+}
+
+// TestCopy tests that copying a record leaves the original in place and
+// replaces the destination.
+func TestCopy(t *testing.T) {
+	d, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Write("tank", "fish", bluefish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Copy(collection, "red", "tank", "fish"); err != nil {
+		t.Fatal("Copy failed: ", err.Error())
+	}
+
+	if err := d.Read(collection, "red", &onefish); err != nil || onefish != redfish {
+		t.Error("Expected the original redfish, got: ", onefish, err)
+	}
+
+	if err := d.Read("tank", "fish", &onefish); err != nil || onefish != redfish {
+		t.Error("Expected the copy to replace bluefish, got: ", onefish, err)
+	}
+}
+
+// TestRenameCollection tests renaming a collection, and refusing to rename it
+// over an existing one or inside itself.
+func TestRenameCollection(t *testing.T) {
+	d, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Write("tank", "blue", bluefish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.RenameCollection(collection, "sea/fish"); err != nil {
+		t.Fatal("RenameCollection failed: ", err.Error())
+	}
+
+	if err := d.Read("sea/fish", "red", &onefish); err != nil || onefish != redfish {
+		t.Error("Expected redfish, got: ", onefish, err)
+	}
+
+	if _, err := d.List(collection); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected the old collection to be gone, got: ", err)
+	}
+
+	if err := d.RenameCollection("sea/fish", "tank"); !stderrors.Is(err, errors.ErrExists) {
+		t.Error("Expected ErrExists, got: ", err)
+	}
+
+	if err := d.RenameCollection("sea", "sea/deep"); !stderrors.Is(err, errors.ErrInvalidPath) {
+		t.Error("Expected ErrInvalidPath, got: ", err)
+	}
+
+	if err := d.RenameCollection("missing", "found"); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected ErrNotFound, got: ", err)
+	}
+}
+
+// TestMoveHistory tests that a moved record leaves its current version in the
+// history of its old location, as a delete would.
+func TestMoveHistory(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{History: &History{}})
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Move(collection, "red", "tank", "red"); err != nil {
+		t.Fatal("Move failed: ", err.Error())
+	}
+
+	versions, err := d.History(collection, "red")
+	if err != nil || len(versions) != 1 {
+		t.Fatal("Expected the moved fish in the history, got: ", versions, err)
+	}
+
+	if err := d.ReadVersion(collection, "red", versions[0].Rev, &onefish); err != nil || onefish != redfish {
+		t.Error("Expected redfish, got: ", onefish, err)
+	}
+}
+
+// TestRenameCollectionLocksSubCollections tests that a rename waits for
+// writers holding the lock of a sub-collection.
+func TestRenameCollectionLocksSubCollections(t *testing.T) {
+	d, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := d.Write("sea/fish", "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	mutex := d.getOrCreateLock("sea/fish")
+	mutex.Lock()
+
+	done := make(chan error)
+	go func() { done <- d.RenameCollection("sea", "ocean") }()
+
+	select {
+	case err := <-done:
+		t.Fatal("Expected the rename to wait for the sub-collection, got: ", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	mutex.Unlock()
+
+	if err := <-done; err != nil {
+		t.Fatal("RenameCollection failed: ", err.Error())
+	}
+
+	if err := d.Read("ocean/fish", "red", &onefish); err != nil || onefish != redfish {
+		t.Error("Expected redfish, got: ", onefish, err)
+	}
+}
@@ -0,0 +1,105 @@
+package scribble
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// operation tracks a single driver operation so that it can be logged and
+// reported to the configured Instrumentation once it completes.
+type operation struct {
+	ctx        context.Context
+	d          *Driver
+	name       string
+	collection string
+	resource   string
+	start      time.Time
+	lockWait   time.Duration
+	bytes      int
+	held       bool // held reports whether the operation holds the driver's lifecycle lock
+}
+
+// begin starts tracking an operation on a collection or resource. The
+// operation holds the driver's lifecycle lock until it ends, so Close waits
+// for it to finish. It never waits for the lock: the lock is only unavailable
+// while Close is waiting for it, and by then the operation is bound to fail
+// with ErrClosed anyway.
+func (d *Driver) begin(ctx context.Context, name, collection, resource string) *operation {
+	held := d.mutex.TryRLock()
+
+	if d.instrumentation != nil {
+		ctx = d.instrumentation.OperationStarted(ctx, name, collection, resource)
+	}
+
+	return &operation{
+		ctx:        ctx,
+		d:          d,
+		name:       name,
+		collection: collection,
+		resource:   resource,
+		start:      time.Now(),
+		held:       held,
+	}
+}
+
+// lock acquires a collection lock exclusively on behalf of the operation,
+// recording how long it waited.
+func (o *operation) lock(l *rwLock) error {
+	start := time.Now()
+	defer func() { o.lockWait += time.Since(start) }()
+
+	return l.LockContext(o.ctx)
+}
+
+// rlock acquires a collection lock shared on behalf of the operation,
+// recording how long it waited.
+func (o *operation) rlock(l *rwLock) error {
+	start := time.Now()
+	defer func() { o.lockWait += time.Since(start) }()
+
+	return l.RLockContext(o.ctx)
+}
+
+// end annotates any error with the operation's context, then logs and
+// reports the outcome. It is meant to be deferred with a pointer to the
+// operation's named error result.
+func (o *operation) end(errp *error) {
+	if o.held {
+		defer o.d.mutex.RUnlock()
+	}
+
+	duration := time.Since(o.start)
+	*errp = errors.WithContext(*errp, o.name, o.collection, o.resource)
+
+	level := slog.LevelDebug
+	attrs := []slog.Attr{
+		slog.String("collection", o.collection),
+		slog.String("resource", o.resource),
+		slog.Duration("duration", duration),
+		slog.Duration("lock_wait", o.lockWait),
+		slog.Int("bytes", o.bytes),
+	}
+
+	if *errp != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.Any("error", *errp))
+	}
+
+	o.d.log.LogAttrs(o.ctx, level, o.name, attrs...)
+
+	if o.d.instrumentation != nil {
+		o.d.instrumentation.OperationFinished(o.ctx, OperationEvent{
+			Op:         o.name,
+			Collection: o.collection,
+			Resource:   o.resource,
+			Duration:   duration,
+			LockWait:   o.lockWait,
+			Bytes:      o.bytes,
+			Err:        *errp,
+			ErrorKind:  errorKind(*errp),
+		})
+	}
+}
@@ -0,0 +1,203 @@
+// Package client provides a scribble.Store that talks to a scribble HTTP
+// server, as served by package server.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/D7682/scribble"
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// Client is a remote scribble.Store backed by a scribble HTTP server.
+type Client struct {
+	base string
+	http *http.Client
+}
+
+// Client implements scribble.Store.
+var _ scribble.Store = (*Client)(nil)
+
+// StatusError is returned when the server responds with an unexpected status.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error implements the error interface for StatusError
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("scribble server responded %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// New creates a new Client for the server at baseURL, such as
+// "http://localhost:8080". If httpClient is nil, http.DefaultClient is used.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		base: strings.TrimSuffix(baseURL, "/"),
+		http: httpClient,
+	}
+}
+
+// Write writes the given data to a resource within a collection on the server.
+func (c *Client) Write(collection, resource string, v interface{}) error {
+	if collection == "" {
+		return errors.ErrMissingCollection
+	}
+
+	if resource == "" {
+		return errors.ErrMissingResource
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.do(http.MethodPut, collection, resource, b)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkStatus(res, collection, resource, http.StatusNoContent)
+}
+
+// Read reads data from a resource within a collection on the server.
+func (c *Client) Read(collection, resource string, v interface{}) error {
+	if collection == "" {
+		return errors.ErrMissingCollection
+	}
+
+	if resource == "" {
+		return errors.ErrMissingResource
+	}
+
+	res, err := c.do(http.MethodGet, collection, resource, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := checkStatus(res, collection, resource, http.StatusOK); err != nil {
+		return err
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// ReadAll retrieves all records from a collection on the server.
+func (c *Client) ReadAll(collection string) ([][]byte, error) {
+	if collection == "" {
+		return nil, errors.ErrMissingCollection
+	}
+
+	res, err := c.do(http.MethodGet, collection, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if err := checkStatus(res, collection, "", http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	var raw []json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	records := make([][]byte, 0, len(raw))
+	for _, record := range raw {
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// Delete removes a resource within a collection on the server.
+func (c *Client) Delete(collection, resource string) error {
+	if collection == "" {
+		return errors.ErrMissingCollection
+	}
+
+	if resource == "" {
+		return errors.ErrMissingResource
+	}
+
+	res, err := c.do(http.MethodDelete, collection, resource, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkStatus(res, collection, resource, http.StatusNoContent)
+}
+
+// DropCollection removes a whole collection on the server. The server must
+// have been created with server.Options.AllowDrop set.
+func (c *Client) DropCollection(collection string) error {
+	if collection == "" {
+		return errors.ErrMissingCollection
+	}
+
+	res, err := c.do(http.MethodDelete, collection, "", nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkStatus(res, collection, "", http.StatusNoContent)
+}
+
+// do sends a request for a collection or a resource within it.
+func (c *Client) do(method, collection, resource string, body []byte) (*http.Response, error) {
+	u := c.base + "/collections/" + url.PathEscape(collection)
+	if resource != "" {
+		u += "/" + url.PathEscape(resource)
+	}
+
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return c.http.Do(req)
+}
+
+// checkStatus converts an unexpected response into the matching error. Missing
+// records are reported as a NotFoundError, as they are by scribble.Driver.
+func checkStatus(res *http.Response, collection, resource string, expected int) error {
+	if res.StatusCode == expected {
+		return nil
+	}
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	b, _ := io.ReadAll(res.Body)
+	if json.Unmarshal(b, &body) != nil {
+		body.Error = string(b)
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		return errors.NewNotFoundError(path.Join(collection, resource), os.ErrNotExist)
+	}
+
+	return &StatusError{StatusCode: res.StatusCode, Message: body.Error}
+}
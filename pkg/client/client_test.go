@@ -0,0 +1,117 @@
+package client
+
+import (
+	stderrors "errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/D7682/scribble"
+	"github.com/D7682/scribble/pkg/errors"
+	"github.com/D7682/scribble/pkg/server"
+)
+
+// Fish represents a fish with a type.
+type Fish struct {
+	Type string `json:"type"`
+}
+
+// newTestClient starts a server over a fresh database and returns a client for it.
+func newTestClient(t *testing.T) *Client {
+	db, err := scribble.New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	ts := httptest.NewServer(server.New(db, &server.Options{AllowDrop: true}))
+	t.Cleanup(ts.Close)
+
+	return New(ts.URL, ts.Client())
+}
+
+// exerciseStore runs the same sequence of operations against any scribble.Store.
+func exerciseStore(t *testing.T, store scribble.Store) {
+	for _, f := range []Fish{{Type: "red"}, {Type: "blue"}} {
+		if err := store.Write("fish", f.Type, f); err != nil {
+			t.Fatal("Create fish failed: ", err.Error())
+		}
+	}
+
+	fish := Fish{}
+	if err := store.Read("fish", "red", &fish); err != nil || fish.Type != "red" {
+		t.Error("Expected red fish, got: ", fish.Type, err)
+	}
+
+	records, err := store.ReadAll("fish")
+	if err != nil || len(records) != 2 {
+		t.Error("Expected two fish, got: ", len(records), err)
+	}
+
+	if err := store.Delete("fish", "red"); err != nil {
+		t.Error("Failed to delete: ", err.Error())
+	}
+
+	var notFound *errors.NotFoundError
+	if err := store.Delete("fish", "red"); !stderrors.As(err, &notFound) {
+		t.Error("Expected NotFoundError, got: ", err)
+	}
+
+	if err := store.DropCollection("fish"); err != nil {
+		t.Error("Failed to delete collection: ", err.Error())
+	}
+
+	if _, err := store.ReadAll("fish"); err == nil {
+		t.Error("Expected nothing, have fish")
+	}
+}
+
+// TestClientMatchesDriver tests that the client behaves like a local driver.
+func TestClientMatchesDriver(t *testing.T) {
+	db, err := scribble.New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	t.Run("driver", func(t *testing.T) { exerciseStore(t, db) })
+	t.Run("client", func(t *testing.T) { exerciseStore(t, newTestClient(t)) })
+}
+
+// TestClientReadMissing tests that reading a missing record reports NotFoundError.
+func TestClientReadMissing(t *testing.T) {
+	c := newTestClient(t)
+
+	var notFound *errors.NotFoundError
+	if err := c.Read("fish", "red", &Fish{}); !stderrors.As(err, &notFound) {
+		t.Error("Expected NotFoundError, got: ", err)
+	}
+
+	if err := c.Write("", "red", Fish{}); err != errors.ErrMissingCollection {
+		t.Error("Expected ErrMissingCollection, got: ", err)
+	}
+}
+
+// TestClientNestedCollection tests records in a nested collection, and with
+// slashes in their IDs, over HTTP.
+func TestClientNestedCollection(t *testing.T) {
+	c := newTestClient(t)
+
+	if err := c.Write("users/admins", "red", Fish{Type: "red"}); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	fish := Fish{}
+	if err := c.Read("users/admins", "red", &fish); err != nil || fish.Type != "red" {
+		t.Error("Expected red fish, got: ", fish.Type, err)
+	}
+
+	if records, err := c.ReadAll("users/admins"); err != nil || len(records) != 1 {
+		t.Error("Expected one fish, got: ", len(records), err)
+	}
+
+	if err := c.Delete("users/admins", "red"); err != nil {
+		t.Error("Failed to delete: ", err.Error())
+	}
+
+	if err := c.Write("fish", "../escape", Fish{}); err == nil {
+		t.Error("Expected the server to refuse an escaping ID, got: ", err)
+	}
+}
@@ -7,8 +7,55 @@ var (
 	// ErrMissingCollection is the error for missing collection
 	ErrMissingCollection = errors.New("missing collection - no place to save record")
 
+	// ErrMissingResource is the error for missing resource
+	ErrMissingResource = errors.New("missing resource - unable to save record")
+
 	// ErrResourceNotFound is the error for missing resource
-	ErrResourceNotFound = errors.New("missing resource - unable to save record")
+	//
+	// Deprecated: ErrResourceNotFound is returned for an empty resource name,
+	// not for a record that does not exist. Use ErrMissingResource, which it
+	// is an alias of, or ErrNotFound for records that do not exist.
+	ErrResourceNotFound = ErrMissingResource
+
+	// ErrNotFound is matched by every NotFoundError, so callers can test for
+	// missing records and collections with errors.Is
+	ErrNotFound = errors.New("not found")
+
+	// ErrKeyNotFound is the error for an encrypted record whose key is unavailable
+	ErrKeyNotFound = errors.New("encryption key not found - unable to decrypt record")
+
+	// ErrCorruptRecord is the error for a stored record that cannot be decrypted
+	// or decompressed
+	ErrCorruptRecord = errors.New("corrupt record - unable to decode record")
+
+	// ErrInvalidRecord is the error for input that is not a valid record
+	ErrInvalidRecord = errors.New("invalid record - unable to store record")
+
+	// ErrNotEmpty is the error for restoring into a directory that already holds data
+	ErrNotEmpty = errors.New("destination is not empty - refusing to overwrite existing data")
+
+	// ErrInvalidPath is the error for a collection or resource name that would
+	// resolve outside of the database root
+	ErrInvalidPath = errors.New("invalid path - collection or resource escapes the database")
+
+	// ErrIsCollection is the error for deleting a collection as if it were a record
+	ErrIsCollection = errors.New("resource is a collection - use DropCollection to remove it")
+
+	// ErrIncompatible is the error for opening a database with options that
+	// conflict with its manifest
+	ErrIncompatible = errors.New("incompatible database - options do not match how the database is stored")
+
+	// ErrReadOnly is the error for modifying a database opened read-only
+	ErrReadOnly = errors.New("read-only database - unable to modify records")
+
+	// ErrClosed is the error for using a driver after it has been closed
+	ErrClosed = errors.New("closed database - unable to use driver after Close")
+
+	// ErrExists is the error for a record or collection that is already present
+	ErrExists = errors.New("already exists - refusing to overwrite existing data")
+
+	// ErrInvalidArchive is the error for a backup archive that cannot be restored
+	ErrInvalidArchive = errors.New("invalid archive - unable to restore database")
 )
 
 // ScribblerError is a custom error interface for the scribbler package with enhanced error handling methods.
@@ -16,4 +63,101 @@ type ScribblerError interface {
 	error
 	Path() string         // Path returns the path associated with the error
 	OriginalError() error // OriginalError returns the original underlying error
+	Unwrap() error        // Unwrap returns the original underlying error for errors.Is and errors.As
+	Op() string           // Op returns the name of the operation that failed, if known
+	Collection() string   // Collection returns the collection the operation acted on, if known
+	Resource() string     // Resource returns the resource the operation acted on, if known
+}
+
+// opContext holds the operation context shared by every scribble error type.
+type opContext struct {
+	op         string
+	collection string
+	resource   string
+}
+
+// Op returns the name of the operation that failed, if known
+func (c *opContext) Op() string {
+	return c.op
+}
+
+// Collection returns the collection the operation acted on, if known
+func (c *opContext) Collection() string {
+	return c.collection
+}
+
+// Resource returns the resource the operation acted on, if known
+func (c *opContext) Resource() string {
+	return c.resource
+}
+
+// prefix renders the operation context as a message prefix, such as
+// "read fish/redfish: ", or an empty string if there is no context.
+func (c *opContext) prefix() string {
+	if c.op == "" {
+		return ""
+	}
+
+	target := c.collection
+	if c.resource != "" {
+		target += "/" + c.resource
+	}
+
+	if target == "" {
+		return c.op + ": "
+	}
+	return c.op + " " + target + ": "
+}
+
+// set fills in the operation context unless it has already been set.
+func (c *opContext) set(op, collection, resource string) {
+	if c.op == "" {
+		*c = opContext{op: op, collection: collection, resource: resource}
+	}
+}
+
+// OpError wraps an error that has no type of its own, such as one of the
+// sentinel errors above, with the operation it came from
+type OpError struct {
+	opContext
+	err error
+}
+
+// Error implements the error interface for OpError
+func (e *OpError) Error() string {
+	return e.prefix() + e.err.Error()
+}
+
+// Path returns the path associated with the error, which OpError does not have
+func (e *OpError) Path() string {
+	return ""
+}
+
+// OriginalError returns the original underlying error
+func (e *OpError) OriginalError() error {
+	return e.err
+}
+
+// Unwrap returns the original underlying error
+func (e *OpError) Unwrap() error {
+	return e.err
+}
+
+// WithContext attaches the operation, collection and resource to err. Scribble
+// error types are annotated in place, keeping any context they already carry;
+// any other error is wrapped in an OpError. A nil error stays nil.
+func WithContext(err error, op, collection, resource string) error {
+	var target interface {
+		set(op, collection, resource string)
+	}
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &target):
+		target.set(op, collection, resource)
+		return err
+	default:
+		return &OpError{opContext: opContext{op: op, collection: collection, resource: resource}, err: err}
+	}
 }
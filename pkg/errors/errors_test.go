@@ -0,0 +1,77 @@
+package errors
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+// TestNotFoundErrorMatching tests that NotFoundError matches its sentinels.
+func TestNotFoundErrorMatching(t *testing.T) {
+	err := NewNotFoundError("fish/red.json", fs.ErrNotExist)
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Error("Expected NotFoundError to match ErrNotFound")
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Error("Expected NotFoundError to unwrap to fs.ErrNotExist")
+	}
+
+	if errors.Is(NewFileIOError("fish", fs.ErrPermission), ErrNotFound) {
+		t.Error("Expected FileIOError not to match ErrNotFound")
+	}
+}
+
+// TestNewPathError tests that missing paths are reported as NotFoundError.
+func TestNewPathError(t *testing.T) {
+	var (
+		notFound *NotFoundError
+		fileIO   *FileIOError
+	)
+
+	if !errors.As(NewPathError("fish", fs.ErrNotExist), &notFound) {
+		t.Error("Expected NotFoundError for a missing path")
+	}
+
+	if !errors.As(NewPathError("fish", fs.ErrPermission), &fileIO) {
+		t.Error("Expected FileIOError for other failures")
+	}
+
+	if !errors.Is(NewPathError("fish", fs.ErrPermission), fs.ErrPermission) {
+		t.Error("Expected FileIOError to unwrap to its original error")
+	}
+}
+
+// TestWithContext tests that operation context is attached to every kind of error.
+func TestWithContext(t *testing.T) {
+	if WithContext(nil, "read", "fish", "red") != nil {
+		t.Error("Expected nil to stay nil")
+	}
+
+	err := WithContext(NewNotFoundError("fish/red.json", fs.ErrNotExist), "read", "fish", "red")
+
+	var se ScribblerError
+	if !errors.As(err, &se) || se.Op() != "read" || se.Collection() != "fish" || se.Resource() != "red" {
+		t.Error("Expected read fish/red context, got: ", err)
+	}
+
+	if err.Error() != "read fish/red: file or directory not found: fish/red.json" {
+		t.Error("Unexpected message: ", err.Error())
+	}
+
+	// Context already present is kept.
+	err = WithContext(err, "update", "other", "thing")
+	if !errors.As(err, &se) || se.Op() != "read" {
+		t.Error("Expected original context to be kept, got: ", se.Op())
+	}
+
+	err = WithContext(ErrMissingResource, "write", "fish", "")
+	if !errors.Is(err, ErrMissingResource) || !errors.Is(err, ErrResourceNotFound) {
+		t.Error("Expected wrapped sentinel to still match")
+	}
+
+	if err.Error() != "write fish: "+ErrMissingResource.Error() {
+		t.Error("Unexpected message: ", err.Error())
+	}
+}
@@ -7,13 +7,14 @@ import (
 
 // FileIOError represents an error related to file I/O operations
 type FileIOError struct {
+	opContext
 	path string
 	err  error
 }
 
 // Error implements the error interface for FileIOError
 func (e *FileIOError) Error() string {
-	return fmt.Sprintf("file I/O error at path %v: %v", e.path, e.err)
+	return fmt.Sprintf("%sfile I/O error at path %v: %v", e.prefix(), e.path, e.err)
 }
 
 // Path returns the path associated with the error
@@ -26,6 +27,11 @@ func (e *FileIOError) OriginalError() error {
 	return e.err
 }
 
+// Unwrap returns the original underlying error
+func (e *FileIOError) Unwrap() error {
+	return e.err
+}
+
 // NewFileIOError creates a new instance of FileIOError
 func NewFileIOError(path string, err error) ScribblerError {
 	return &FileIOError{
@@ -1,10 +1,17 @@
 // pkg/errors/not_found_error.go
 package errors
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+)
 
-// NotFoundError is a custom error type for file or directory not found errors
+// NotFoundError is a custom error type for file or directory not found errors.
+// It matches ErrNotFound with errors.Is, as well as its underlying error,
+// which is usually fs.ErrNotExist
 type NotFoundError struct {
+	opContext
 	path string
 	err  error
 }
@@ -15,7 +22,7 @@ func NewNotFoundError(path string, err error) ScribblerError {
 }
 
 func (e *NotFoundError) Error() string {
-	return fmt.Sprintf("File or directory not found: %s", e.path)
+	return fmt.Sprintf("%sfile or directory not found: %s", e.prefix(), e.path)
 }
 
 // Path returns the path associated with the error
@@ -27,3 +34,22 @@ func (e *NotFoundError) Path() string {
 func (e *NotFoundError) OriginalError() error {
 	return e.err
 }
+
+// Unwrap returns the original underlying error
+func (e *NotFoundError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether target is ErrNotFound
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// NewPathError creates a NotFoundError if err reports that path does not
+// exist, and a FileIOError otherwise
+func NewPathError(path string, err error) ScribblerError {
+	if errors.Is(err, fs.ErrNotExist) {
+		return NewNotFoundError(path, err)
+	}
+	return NewFileIOError(path, err)
+}
@@ -0,0 +1,586 @@
+// Package logstore provides a scribble.Store that keeps each collection in a
+// single append-only log file, in the style of Bitcask.
+//
+// Every write or delete appends an entry to the collection's log, and an
+// in-memory index maps each record to the position of its latest value, so
+// reads take a single positioned read and ReadAll never lists a directory.
+// Overwritten and deleted values stay in the log until the collection is
+// compacted, which rewrites it with only the live records. Compaction runs
+// automatically once enough of a log is dead, or on demand with Compact.
+//
+// Each entry is laid out as
+//
+//	crc32 (4) | kind (1) | key length (4) | value length (4) | key | value
+//
+// with integers in big-endian order and the CRC covering everything after it.
+// A log whose final entry was cut short by a crash is truncated back to its
+// last complete entry when the collection is opened. An entry that claims to
+// run past the end of the log is only treated as cut short when no valid
+// entry follows it; otherwise its header is damaged, and the log is left
+// alone and reported as corrupt.
+package logstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/D7682/scribble"
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+const (
+	// ext is the extension of a collection's log file.
+	ext = ".log"
+
+	// headerSize is the size of an entry header.
+	headerSize = 4 + 1 + 4 + 4
+
+	// defaultCompactAfter is the default number of dead bytes after which a
+	// collection is compacted.
+	defaultCompactAfter = 4 << 20
+
+	// scanBudget bounds the bytes entryAfter checksums, as a multiple of the
+	// size of the log it scans.
+	scanBudget = 4
+)
+
+const (
+	kindPut byte = iota
+	kindDelete
+)
+
+// Store is a scribble.Store backed by one append-only log file per collection.
+type Store struct {
+	dir          string
+	compactAfter int64
+
+	mutex       sync.Mutex
+	collections map[string]*collection
+}
+
+// Store implements scribble.Store.
+var _ scribble.Store = (*Store)(nil)
+
+// Options represents the optional configurations for a Store.
+type Options struct {
+	// CompactAfter is the number of bytes of overwritten and deleted values a
+	// collection's log may hold before it is compacted automatically, as long
+	// as they also make up at least half of the log. Zero selects 4 MiB and a
+	// negative value disables automatic compaction.
+	CompactAfter int64
+}
+
+// collection is an open log file and the index of the records in it.
+type collection struct {
+	mutex sync.RWMutex
+	path  string
+	file  *os.File
+	size  int64            // size is the length of the log
+	dead  int64            // dead is the number of bytes in entries that are no longer live
+	index map[string]entry // index maps each live record to its latest value
+}
+
+// entry is the location of a value within a log.
+type entry struct {
+	offset int64 // offset is the position of the whole entry
+	size   int64 // size is the length of the whole entry
+	value  int64 // value is the position of the value
+	length int   // length is the length of the value
+}
+
+// Open creates a Store in dir, creating the directory if it does not exist.
+// Collections are opened, and their index built, the first time they are used.
+func Open(dir string, options *Options) (*Store, error) {
+	opts := Options{}
+
+	if options != nil {
+		opts = *options
+	}
+
+	if opts.CompactAfter == 0 {
+		opts.CompactAfter = defaultCompactAfter
+	}
+
+	dir = filepath.Clean(dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.NewFileIOError(dir, err)
+	}
+
+	return &Store{
+		dir:          dir,
+		compactAfter: opts.CompactAfter,
+		collections:  map[string]*collection{},
+	}, nil
+}
+
+// Close closes the log file of every open collection.
+func (s *Store) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var firstErr error
+	for name, c := range s.collections {
+		c.mutex.Lock()
+		if err := c.file.Close(); err != nil && firstErr == nil {
+			firstErr = errors.NewFileIOError(c.path, err)
+		}
+		c.mutex.Unlock()
+		delete(s.collections, name)
+	}
+
+	return firstErr
+}
+
+// Write appends the given data to the log of a collection as the new value of
+// a resource.
+func (s *Store) Write(name, resource string, v interface{}) error {
+	if resource == "" {
+		return errors.ErrMissingResource
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	c, err := s.open(name, true)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.append(kindPut, resource, b); err != nil {
+		return err
+	}
+
+	return s.maybeCompact(c)
+}
+
+// Read reads the latest value of a resource within a collection.
+func (s *Store) Read(name, resource string, v interface{}) error {
+	if resource == "" {
+		return errors.ErrMissingResource
+	}
+
+	c, err := s.open(name, false)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	e, ok := c.index[resource]
+	if !ok {
+		return errors.NewNotFoundError(path.Join(name, resource), os.ErrNotExist)
+	}
+
+	b, err := c.read(e)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// ReadAll retrieves the latest value of every record in a collection, ordered
+// by resource name as scribble.Driver orders them.
+func (s *Store) ReadAll(name string) ([][]byte, error) {
+	c, err := s.open(name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	var records [][]byte
+	for _, key := range c.keys() {
+		b, err := c.read(c.index[key])
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, b)
+	}
+
+	return records, nil
+}
+
+// List returns the names of all records in a collection, in lexical order.
+func (s *Store) List(name string) ([]string, error) {
+	c, err := s.open(name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.keys(), nil
+}
+
+// Delete appends a tombstone for a resource to the log of a collection.
+func (s *Store) Delete(name, resource string) error {
+	if resource == "" {
+		return errors.ErrMissingResource
+	}
+
+	c, err := s.open(name, false)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.index[resource]; !ok {
+		return errors.NewNotFoundError(path.Join(name, resource), os.ErrNotExist)
+	}
+
+	if err := c.append(kindDelete, resource, nil); err != nil {
+		return err
+	}
+
+	return s.maybeCompact(c)
+}
+
+// DropCollection removes the log of a collection.
+func (s *Store) DropCollection(name string) error {
+	c, err := s.open(name, false)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(s.collections, name)
+	c.file.Close()
+
+	if err := os.Remove(c.path); err != nil {
+		return errors.NewFileIOError(c.path, err)
+	}
+
+	return nil
+}
+
+// Compact rewrites the log of a collection so it holds only the latest value
+// of each live record.
+func (s *Store) Compact(name string) error {
+	c, err := s.open(name, false)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.compact()
+}
+
+// open is a helper function for returning an open collection, reading its
+// log and building its index if necessary. A collection without a log is
+// reported as not found unless create is set.
+func (s *Store) open(name string, create bool) (*collection, error) {
+	if name == "" {
+		return nil, errors.ErrMissingCollection
+	}
+
+	if !filepath.IsLocal(name) {
+		return nil, errors.ErrInvalidPath
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if c, ok := s.collections[name]; ok {
+		return c, nil
+	}
+
+	p := filepath.Join(s.dir, name+ext)
+
+	flags := os.O_RDWR
+	if create {
+		flags |= os.O_CREATE
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return nil, errors.NewFileIOError(p, err)
+		}
+	}
+
+	f, err := os.OpenFile(p, flags, 0644)
+	if os.IsNotExist(err) {
+		return nil, errors.NewNotFoundError(name, err)
+	} else if err != nil {
+		return nil, errors.NewFileIOError(p, err)
+	}
+
+	c := &collection{path: p, file: f}
+	if err := c.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	s.collections[name] = c
+	return c, nil
+}
+
+// maybeCompact is a helper function for compacting a collection once the
+// dead part of its log passes the configured threshold.
+func (s *Store) maybeCompact(c *collection) error {
+	if s.compactAfter < 0 || c.dead < s.compactAfter || c.dead*2 < c.size {
+		return nil
+	}
+
+	return c.compact()
+}
+
+// load is a helper function for reading the whole log and building the index.
+// A final entry that was cut short is truncated away; any other damage is
+// reported as ErrCorruptRecord, without modifying the log.
+func (c *collection) load() error {
+	fi, err := c.file.Stat()
+	if err != nil {
+		return errors.NewFileIOError(c.path, err)
+	}
+
+	c.index = map[string]entry{}
+	c.size, c.dead = 0, 0
+
+	r := io.NewSectionReader(c.file, 0, fi.Size())
+	for c.size < fi.Size() {
+		kind, key, e, err := readEntry(r, c.size, fi.Size())
+		if err == io.ErrUnexpectedEOF {
+			if entryAfter(r, c.size, fi.Size()) {
+				return errors.NewFileIOError(c.path, errors.ErrCorruptRecord)
+			}
+			break
+		} else if err != nil {
+			return errors.NewFileIOError(c.path, err)
+		}
+
+		c.apply(kind, key, e)
+	}
+
+	if c.size < fi.Size() {
+		if err := c.file.Truncate(c.size); err != nil {
+			return errors.NewFileIOError(c.path, err)
+		}
+	}
+
+	return nil
+}
+
+// readEntry is a helper function for decoding the entry at offset. It returns
+// io.ErrUnexpectedEOF if the entry runs past the end of the log.
+func readEntry(r *io.SectionReader, offset, limit int64) (byte, string, entry, error) {
+	var header [headerSize]byte
+	if _, err := r.ReadAt(header[:], offset); err != nil {
+		return 0, "", entry{}, io.ErrUnexpectedEOF
+	}
+
+	sum := binary.BigEndian.Uint32(header[0:4])
+	kind := header[4]
+	keyLen := int64(binary.BigEndian.Uint32(header[5:9]))
+	valLen := int64(binary.BigEndian.Uint32(header[9:13]))
+
+	size := headerSize + keyLen + valLen
+	if offset+size > limit {
+		return 0, "", entry{}, io.ErrUnexpectedEOF
+	}
+
+	body := make([]byte, keyLen+valLen)
+	if _, err := r.ReadAt(body, offset+headerSize); err != nil {
+		return 0, "", entry{}, err
+	}
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(body)
+	if crc.Sum32() != sum || kind > kindDelete {
+		return 0, "", entry{}, errors.ErrCorruptRecord
+	}
+
+	e := entry{offset: offset, size: size, value: offset + headerSize + keyLen, length: int(valLen)}
+	return kind, string(body[:keyLen]), e, nil
+}
+
+// entryAfter is a helper function for reporting whether a valid entry starts
+// anywhere in the log after offset. An entry that runs past the end of the log
+// but is followed by a valid one was not cut short by a crash, since entries
+// are only ever appended.
+//
+// The log is read once, sequentially, and only offsets whose headers are
+// plausible have their checksums computed, streaming the body through a
+// single buffer. The bytes checksummed are limited to a few times the rest of
+// the log; should that run out, a valid entry is assumed, so the log is
+// reported as corrupt rather than truncated.
+func entryAfter(r *io.SectionReader, offset, limit int64) bool {
+	budget := scanBudget * (limit - offset)
+	buf := make([]byte, 32<<10)
+	br := bufio.NewReaderSize(io.NewSectionReader(r, offset+1, limit-offset-1), 64<<10)
+
+	for off := offset + 1; off+headerSize <= limit; off++ {
+		header, err := br.Peek(headerSize)
+		if err != nil {
+			return false
+		}
+
+		sum := binary.BigEndian.Uint32(header[0:4])
+		kind := header[4]
+		keyLen := int64(binary.BigEndian.Uint32(header[5:9]))
+		valLen := int64(binary.BigEndian.Uint32(header[9:13]))
+
+		if kind <= kindDelete && keyLen > 0 && off+headerSize+keyLen+valLen <= limit {
+			if budget -= keyLen + valLen; budget < 0 {
+				return true
+			}
+
+			crc := crc32.NewIEEE()
+			crc.Write(header[4:])
+			body := io.NewSectionReader(r, off+headerSize, keyLen+valLen)
+			if _, err := io.CopyBuffer(crc, body, buf); err == nil && crc.Sum32() == sum {
+				return true
+			}
+		}
+
+		br.Discard(1)
+	}
+
+	return false
+}
+
+// apply is a helper function for updating the index and size accounting with
+// an entry that has been read from or appended to the log.
+func (c *collection) apply(kind byte, key string, e entry) {
+	if old, ok := c.index[key]; ok {
+		c.dead += old.size
+	}
+
+	if kind == kindDelete {
+		delete(c.index, key)
+		c.dead += e.size
+	} else {
+		c.index[key] = e
+	}
+
+	c.size = e.offset + e.size
+}
+
+// append is a helper function for adding an entry to the end of the log.
+func (c *collection) append(kind byte, key string, value []byte) error {
+	b := encodeEntry(kind, key, value)
+
+	if _, err := c.file.WriteAt(b, c.size); err != nil {
+		return errors.NewFileIOError(c.path, err)
+	}
+
+	c.apply(kind, key, entry{
+		offset: c.size,
+		size:   int64(len(b)),
+		value:  c.size + headerSize + int64(len(key)),
+		length: len(value),
+	})
+
+	return nil
+}
+
+// encodeEntry is a helper function for encoding a single log entry.
+func encodeEntry(kind byte, key string, value []byte) []byte {
+	b := make([]byte, headerSize, headerSize+len(key)+len(value))
+	b[4] = kind
+	binary.BigEndian.PutUint32(b[5:9], uint32(len(key)))
+	binary.BigEndian.PutUint32(b[9:13], uint32(len(value)))
+	b = append(append(b, key...), value...)
+
+	binary.BigEndian.PutUint32(b[0:4], crc32.ChecksumIEEE(b[4:]))
+	return b
+}
+
+// read is a helper function for reading the value of an index entry.
+func (c *collection) read(e entry) ([]byte, error) {
+	b := make([]byte, e.length)
+	if _, err := c.file.ReadAt(b, e.value); err != nil {
+		return nil, errors.NewFileIOError(c.path, err)
+	}
+
+	return b, nil
+}
+
+// keys is a helper function for listing the live records in lexical order.
+func (c *collection) keys() []string {
+	keys := make([]string, 0, len(c.index))
+	for key := range c.index {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// compact is a helper function for rewriting the log with only the live
+// records, by way of a temporary file and a rename.
+func (c *collection) compact() error {
+	tmpPath := c.path + ".tmp"
+	if err := c.writeLive(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		return errors.NewFileIOError(c.path, err)
+	}
+
+	f, err := os.OpenFile(c.path, os.O_RDWR, 0644)
+	if err != nil {
+		return errors.NewFileIOError(c.path, err)
+	}
+
+	c.file.Close()
+	c.file = f
+
+	return c.load()
+}
+
+// writeLive is a helper function for writing the live records to a new log.
+func (c *collection) writeLive(p string) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return errors.NewFileIOError(p, err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, key := range c.keys() {
+		b, err := c.read(c.index[key])
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(encodeEntry(kindPut, key, b)); err != nil {
+			return errors.NewFileIOError(p, err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return errors.NewFileIOError(p, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.NewFileIOError(p, err)
+	}
+
+	return nil
+}
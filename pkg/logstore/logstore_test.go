@@ -0,0 +1,241 @@
+package logstore
+
+import (
+	"encoding/binary"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/D7682/scribble/example"
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// Fish represents a fish with a type.
+type Fish struct {
+	Type string `json:"type"`
+}
+
+// openStore opens a Store in dir, failing the test on error.
+func openStore(t *testing.T, dir string, options *Options) *Store {
+	s, err := Open(dir, options)
+	if err != nil {
+		t.Fatal("Failed to open store: ", err.Error())
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// TestStore tests the Store semantics shared with scribble.Driver.
+func TestStore(t *testing.T) {
+	fishing := example.NewFishingExample(openStore(t, t.TempDir(), nil))
+
+	for _, name := range []string{"red", "blue"} {
+		if err := fishing.WriteFishToDatabase(name); err != nil {
+			t.Fatal("Create fish failed: ", err.Error())
+		}
+	}
+
+	if fish, err := fishing.ReadFishFromDatabase("red"); err != nil || fish.Name != "red" {
+		t.Error("Expected red fish, got: ", fish, err)
+	}
+
+	if err := fishing.DeleteFishFromDatabase("red"); err != nil {
+		t.Fatal("Delete fish failed: ", err.Error())
+	}
+
+	if _, err := fishing.ReadFishFromDatabase("red"); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected ErrNotFound for a deleted fish, got: ", err)
+	}
+
+	if err := fishing.DeleteFishFromDatabase("red"); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected ErrNotFound deleting twice, got: ", err)
+	}
+
+	if fishies, err := fishing.ReadAllFishFromDatabase(); err != nil || len(fishies) != 1 {
+		t.Error("Expected one fish, got: ", fishies, err)
+	}
+
+	if err := fishing.DeleteAllFishFromDatabase(); err != nil {
+		t.Fatal("Drop collection failed: ", err.Error())
+	}
+
+	if _, err := fishing.ReadAllFishFromDatabase(); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected ErrNotFound for a dropped collection, got: ", err)
+	}
+}
+
+// TestReopen tests that the index is rebuilt from the log.
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, nil)
+
+	s.Write("fish", "red", Fish{Type: "red"})
+	s.Write("fish", "red", Fish{Type: "crimson"})
+	s.Write("fish", "blue", Fish{Type: "blue"})
+	s.Delete("fish", "blue")
+	s.Close()
+
+	s = openStore(t, dir, nil)
+
+	fish := Fish{}
+	if err := s.Read("fish", "red", &fish); err != nil || fish.Type != "crimson" {
+		t.Error("Expected the latest red fish, got: ", fish, err)
+	}
+
+	if ids, err := s.List("fish"); err != nil || len(ids) != 1 {
+		t.Error("Expected the deleted fish to stay deleted, got: ", ids, err)
+	}
+}
+
+// TestTornWrite tests that a log cut short by a crash is truncated on open.
+func TestTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, nil)
+
+	s.Write("fish", "red", Fish{Type: "red"})
+	s.Write("fish", "blue", Fish{Type: "blue"})
+	s.Close()
+
+	log := filepath.Join(dir, "fish.log")
+	fi, err := os.Stat(log)
+	if err != nil {
+		t.Fatal("Failed to stat log: ", err.Error())
+	}
+
+	if err := os.Truncate(log, fi.Size()-3); err != nil {
+		t.Fatal("Failed to tear log: ", err.Error())
+	}
+
+	s = openStore(t, dir, nil)
+
+	if ids, err := s.List("fish"); err != nil || len(ids) != 1 || ids[0] != "red" {
+		t.Error("Expected only the red fish to survive, got: ", ids, err)
+	}
+
+	if err := s.Write("fish", "green", Fish{Type: "green"}); err != nil {
+		t.Error("Expected writes after recovery to succeed, got: ", err)
+	}
+}
+
+// TestCorruptLog tests that damage before the end of the log is reported.
+func TestCorruptLog(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, nil)
+
+	s.Write("fish", "red", Fish{Type: "red"})
+	s.Write("fish", "blue", Fish{Type: "blue"})
+	s.Close()
+
+	log := filepath.Join(dir, "fish.log")
+	b, _ := os.ReadFile(log)
+	b[headerSize] ^= 0xff
+	os.WriteFile(log, b, 0644)
+
+	s = openStore(t, dir, nil)
+
+	if _, err := s.ReadAll("fish"); !stderrors.Is(err, errors.ErrCorruptRecord) {
+		t.Error("Expected ErrCorruptRecord, got: ", err)
+	}
+}
+
+// TestCorruptLength tests that a damaged length in the middle of the log is
+// reported rather than mistaken for a torn write and truncated away.
+func TestCorruptLength(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, nil)
+
+	s.Write("fish", "red", Fish{Type: "red"})
+	s.Write("fish", "blue", Fish{Type: "blue"})
+	s.Write("fish", "green", Fish{Type: "green"})
+	s.Close()
+
+	log := filepath.Join(dir, "fish.log")
+	b, _ := os.ReadFile(log)
+	b[9] ^= 0xff
+	os.WriteFile(log, b, 0644)
+
+	s = openStore(t, dir, nil)
+
+	if _, err := s.ReadAll("fish"); !stderrors.Is(err, errors.ErrCorruptRecord) {
+		t.Error("Expected ErrCorruptRecord, got: ", err)
+	}
+
+	after, _ := os.ReadFile(log)
+	if len(after) != len(b) {
+		t.Errorf("Expected the log to be left at %d bytes, got: %d", len(b), len(after))
+	}
+}
+
+// TestCorruptScanBounded tests that looking past a damaged entry stays cheap
+// even when the rest of the log is full of plausible entry headers.
+func TestCorruptScanBounded(t *testing.T) {
+	dir := t.TempDir()
+
+	// An entry claiming to run far past the end of the log, followed by
+	// bytes that look like the header of a large entry at almost every offset.
+	b := encodeEntry(kindPut, "red", []byte(`{"type":"red"}`))
+	binary.BigEndian.PutUint32(b[9:13], 1<<30)
+	for i := 0; i < 256<<10; i++ {
+		if i%4 == 3 {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "fish.log"), b, 0644); err != nil {
+		t.Fatal("Failed to write log: ", err.Error())
+	}
+
+	s := openStore(t, dir, nil)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	if _, err := s.ReadAll("fish"); !stderrors.Is(err, errors.ErrCorruptRecord) {
+		t.Error("Expected ErrCorruptRecord, got: ", err)
+	}
+
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 16*uint64(len(b)) {
+		t.Errorf("Expected the scan to allocate little, got %d bytes for a %d byte log", n, len(b))
+	}
+}
+
+// TestCompact tests that compaction drops dead values, both on demand and automatically.
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, &Options{CompactAfter: -1})
+
+	for i := 0; i < 100; i++ {
+		s.Write("fish", "red", Fish{Type: "red"})
+	}
+
+	log := filepath.Join(dir, "fish.log")
+	before, _ := os.Stat(log)
+
+	if err := s.Compact("fish"); err != nil {
+		t.Fatal("Compact failed: ", err.Error())
+	}
+
+	after, _ := os.Stat(log)
+	if after.Size()*100 != before.Size() {
+		t.Errorf("Expected the log to shrink to one entry, got %d of %d bytes", after.Size(), before.Size())
+	}
+
+	auto := openStore(t, t.TempDir(), &Options{CompactAfter: 1})
+	for i := 0; i < 100; i++ {
+		auto.Write("fish", "red", Fish{Type: "red"})
+	}
+
+	if c := auto.collections["fish"]; c.size > 2*after.Size() {
+		t.Errorf("Expected automatic compaction, got a %d byte log", c.size)
+	}
+
+	fish := Fish{}
+	if err := s.Read("fish", "red", &fish); err != nil || fish.Type != "red" {
+		t.Error("Expected red fish after compaction, got: ", fish, err)
+	}
+}
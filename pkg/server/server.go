@@ -0,0 +1,288 @@
+// Package server exposes a scribble database over HTTP.
+//
+// Records are addressed as /collections/{collection}/{id}, with any slash in
+// a nested collection escaped as %2F. IDs cannot contain slashes:
+//
+//	GET    /collections/{collection}       all records in a collection, as a JSON array
+//	GET    /collections/{collection}/{id}  a single record
+//	PUT    /collections/{collection}/{id}  create or replace a record with the request body
+//	DELETE /collections/{collection}/{id}  delete a record
+//	DELETE /collections/{collection}       delete a whole collection, if Options.AllowDrop is set
+//
+// Request bodies are stored byte for byte, so a record reads back exactly as it
+// was written. Bodies larger than Options.MaxBodyBytes are rejected. Single record responses, including those to PUT, carry an ETag
+// derived from the stored revision of the record. GET honors If-None-Match,
+// and PUT honors If-Match and If-None-Match: * so clients can make
+// conditional, lost-update-free writes.
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/D7682/scribble"
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+const (
+	// prefix is the path under which collections are served.
+	prefix = "/collections/"
+
+	// defaultMaxBodyBytes is the default limit on the size of a request body.
+	defaultMaxBodyBytes = 8 << 20
+)
+
+// errPreconditionFailed is returned from an update when a conditional request
+// header does not match the current record.
+var errPreconditionFailed = stderrors.New("precondition failed")
+
+// Server is an http.Handler serving a scribble database.
+type Server struct {
+	db           *scribble.Driver
+	allowDrop    bool
+	maxBodyBytes int64
+}
+
+// Options represents the optional configurations for a Server.
+type Options struct {
+	// AllowDrop enables DELETE on a whole collection. It is off by default, so
+	// a single request cannot wipe out a collection by accident.
+	AllowDrop bool
+
+	// MaxBodyBytes limits the size of a request body; larger requests are
+	// rejected with 413 Request Entity Too Large. Zero selects 8 MiB and a
+	// negative value removes the limit.
+	MaxBodyBytes int64
+}
+
+// New creates a new Server for the given database driver.
+func New(db *scribble.Driver, options *Options) *Server {
+	opts := Options{}
+
+	if options != nil {
+		opts = *options
+	}
+
+	if opts.MaxBodyBytes == 0 {
+		opts.MaxBodyBytes = defaultMaxBodyBytes
+	}
+
+	return &Server{db: db, allowDrop: opts.AllowDrop, maxBodyBytes: opts.MaxBodyBytes}
+}
+
+// ServeHTTP implements the http.Handler interface for Server.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	collection, id, ok := parsePath(r.URL.EscapedPath())
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		s.readAll(w, r, collection)
+	case id == "" && r.Method == http.MethodDelete && s.allowDrop:
+		s.dropCollection(w, r, collection)
+	case id == "" && s.allowDrop:
+		methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+	case id == "":
+		methodNotAllowed(w, http.MethodGet)
+	case r.Method == http.MethodGet:
+		s.read(w, r, collection, id)
+	case r.Method == http.MethodPut:
+		s.write(w, r, collection, id)
+	case r.Method == http.MethodDelete:
+		s.delete(w, r, collection, id)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// readAll responds with every record in a collection.
+func (s *Server) readAll(w http.ResponseWriter, r *http.Request, collection string) {
+	records, err := s.db.ReadAllContext(r.Context(), collection)
+	if err != nil {
+		writeDriverError(w, err)
+		return
+	}
+
+	raw := make([]json.RawMessage, 0, len(records))
+	for _, record := range records {
+		raw = append(raw, record)
+	}
+
+	writeJSON(w, http.StatusOK, raw)
+}
+
+// read responds with a single record.
+func (s *Server) read(w http.ResponseWriter, r *http.Request, collection, id string) {
+	record, err := s.db.ReadRawContext(r.Context(), collection, id)
+	if err != nil {
+		writeDriverError(w, err)
+		return
+	}
+
+	tag := etag(record)
+	w.Header().Set("ETag", tag)
+
+	if matchETag(r.Header.Get("If-None-Match"), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(record)
+}
+
+// write creates or replaces a single record with the request body, storing it
+// unchanged.
+func (s *Server) write(w http.ResponseWriter, r *http.Request, collection, id string) {
+	if s.maxBodyBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	var tooLarge *http.MaxBytesError
+	if stderrors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	} else if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	ifNoneMatch := r.Header.Get("If-None-Match")
+
+	err = s.db.UpdateContext(r.Context(), collection, id, func(current json.RawMessage) (interface{}, error) {
+		if ifMatch != "" && (current == nil || !matchETag(ifMatch, etag(current))) {
+			return nil, errPreconditionFailed
+		}
+
+		if ifNoneMatch != "" && current != nil && matchETag(ifNoneMatch, etag(current)) {
+			return nil, errPreconditionFailed
+		}
+
+		return json.RawMessage(body), nil
+	})
+	if err != nil {
+		writeDriverError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(body))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// dropCollection removes a whole collection.
+func (s *Server) dropCollection(w http.ResponseWriter, r *http.Request, collection string) {
+	if err := s.db.DropCollectionContext(r.Context(), collection); err != nil {
+		writeDriverError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// delete removes a single record.
+func (s *Server) delete(w http.ResponseWriter, r *http.Request, collection, id string) {
+	if err := s.db.DeleteContext(r.Context(), collection, id); err != nil {
+		writeDriverError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parsePath splits an escaped request path into a collection and an optional
+// record ID. Each is a single path segment, so the slashes of a nested
+// collection must be escaped as %2F.
+func parsePath(p string) (collection, id string, ok bool) {
+	if !strings.HasPrefix(p, prefix) {
+		return "", "", false
+	}
+
+	parts := strings.Split(strings.TrimPrefix(p, prefix), "/")
+	for i, part := range parts {
+		part, err := url.PathUnescape(part)
+		if err != nil || part == "" || part == "." || part == ".." {
+			return "", "", false
+		}
+		parts[i] = part
+	}
+
+	switch len(parts) {
+	case 1:
+		return parts[0], "", true
+	case 2:
+		return parts[0], parts[1], true
+	default:
+		return "", "", false
+	}
+}
+
+// etag returns the entity tag identifying a revision of a stored record.
+// Surrounding whitespace is ignored, as it is not part of the JSON value.
+func etag(record []byte) string {
+	sum := sha256.Sum256(bytes.TrimSpace(record))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// matchETag reports whether an If-Match or If-None-Match header value matches
+// the given entity tag.
+func matchETag(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeDriverError maps an error returned by the driver to an HTTP response.
+func writeDriverError(w http.ResponseWriter, err error) {
+	switch {
+	case stderrors.Is(err, errPreconditionFailed):
+		writeError(w, http.StatusPreconditionFailed, err.Error())
+	case stderrors.Is(err, errors.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case stderrors.Is(err, errors.ErrMissingCollection), stderrors.Is(err, errors.ErrMissingResource),
+		stderrors.Is(err, errors.ErrInvalidPath), stderrors.Is(err, errors.ErrInvalidRecord):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case stderrors.Is(err, errors.ErrIsCollection):
+		writeError(w, http.StatusConflict, err.Error())
+	case stderrors.Is(err, errors.ErrReadOnly):
+		writeError(w, http.StatusForbidden, err.Error())
+	case stderrors.Is(err, errors.ErrClosed):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// methodNotAllowed responds with 405 and the list of allowed methods.
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+// writeError responds with a JSON error body.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// writeJSON responds with v encoded as JSON.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
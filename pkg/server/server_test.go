@@ -0,0 +1,161 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/D7682/scribble"
+)
+
+// newTestServer starts a server over a fresh database.
+func newTestServer(t *testing.T, options *Options) *httptest.Server {
+	db, err := scribble.New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	ts := httptest.NewServer(New(db, options))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// do sends a request to the test server and returns the response.
+func do(t *testing.T, method, url, body string, header map[string]string) *http.Response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal("Failed to create request: ", err.Error())
+	}
+
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("Request failed: ", err.Error())
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+// assertStatus asserts that a response has the expected status code.
+func assertStatus(t *testing.T, res *http.Response, expected int) {
+	t.Helper()
+	if res.StatusCode != expected {
+		b, _ := io.ReadAll(res.Body)
+		t.Errorf("Expected status %d, got: %d %s", expected, res.StatusCode, b)
+	}
+}
+
+// TestCRUD tests writing, reading, listing and deleting a record over HTTP.
+func TestCRUD(t *testing.T) {
+	ts := newTestServer(t, nil)
+	url := ts.URL + "/collections/fish/red"
+
+	assertStatus(t, do(t, http.MethodPut, url, `{"type":"red"}`, nil), http.StatusNoContent)
+
+	res := do(t, http.MethodGet, url, "", nil)
+	assertStatus(t, res, http.StatusOK)
+
+	fish := map[string]string{}
+	if err := json.NewDecoder(res.Body).Decode(&fish); err != nil || fish["type"] != "red" {
+		t.Error("Expected red fish, got: ", fish)
+	}
+
+	res = do(t, http.MethodGet, ts.URL+"/collections/fish", "", nil)
+	assertStatus(t, res, http.StatusOK)
+
+	var all []map[string]string
+	if err := json.NewDecoder(res.Body).Decode(&all); err != nil || len(all) != 1 {
+		t.Error("Expected one fish, got: ", all)
+	}
+
+	assertStatus(t, do(t, http.MethodDelete, url, "", nil), http.StatusNoContent)
+	assertStatus(t, do(t, http.MethodGet, url, "", nil), http.StatusNotFound)
+	assertStatus(t, do(t, http.MethodDelete, url, "", nil), http.StatusNotFound)
+}
+
+// TestETags tests conditional requests based on record revisions.
+func TestETags(t *testing.T) {
+	ts := newTestServer(t, nil)
+	url := ts.URL + "/collections/fish/red"
+
+	assertStatus(t, do(t, http.MethodPut, url, `{"type":"red"}`, map[string]string{"If-None-Match": "*"}), http.StatusNoContent)
+	assertStatus(t, do(t, http.MethodPut, url, `{"type":"red"}`, map[string]string{"If-None-Match": "*"}), http.StatusPreconditionFailed)
+
+	res := do(t, http.MethodGet, url, "", nil)
+	tag := res.Header.Get("ETag")
+	if tag == "" {
+		t.Fatal("Expected an ETag")
+	}
+
+	assertStatus(t, do(t, http.MethodGet, url, "", map[string]string{"If-None-Match": tag}), http.StatusNotModified)
+	assertStatus(t, do(t, http.MethodPut, url, `{"type":"blue"}`, map[string]string{"If-Match": tag}), http.StatusNoContent)
+
+	// The record has changed, so the old tag no longer matches.
+	assertStatus(t, do(t, http.MethodPut, url, `{"type":"green"}`, map[string]string{"If-Match": tag}), http.StatusPreconditionFailed)
+}
+
+// TestPutVerbatim tests that a record reads back byte for byte as it was put,
+// and that PUT returns the ETag that GET does.
+func TestPutVerbatim(t *testing.T) {
+	ts := newTestServer(t, nil)
+	url := ts.URL + "/collections/fish/red"
+	body := `{"type":"red","fins":2.50,"a":1}`
+
+	put := do(t, http.MethodPut, url, body, nil)
+	assertStatus(t, put, http.StatusNoContent)
+
+	res := do(t, http.MethodGet, url, "", nil)
+	b, _ := io.ReadAll(res.Body)
+	if string(b) != body {
+		t.Error("Expected the record unchanged, got: ", string(b))
+	}
+
+	if tag := put.Header.Get("ETag"); tag == "" || tag != res.Header.Get("ETag") {
+		t.Error("Expected PUT and GET to agree on the ETag, got: ", tag, res.Header.Get("ETag"))
+	}
+}
+
+// TestBadRequests tests that malformed requests are rejected.
+func TestBadRequests(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	assertStatus(t, do(t, http.MethodPut, ts.URL+"/collections/fish/red", `{`, nil), http.StatusBadRequest)
+	assertStatus(t, do(t, http.MethodPost, ts.URL+"/collections/fish/red", `{}`, nil), http.StatusMethodNotAllowed)
+	assertStatus(t, do(t, http.MethodGet, ts.URL+"/collections/fish/red/gills", "", nil), http.StatusNotFound)
+	assertStatus(t, do(t, http.MethodGet, ts.URL+"/other", "", nil), http.StatusNotFound)
+}
+
+// TestDropCollection tests that deleting a whole collection requires AllowDrop.
+func TestDropCollection(t *testing.T) {
+	for _, allow := range []bool{false, true} {
+		ts := newTestServer(t, &Options{AllowDrop: allow})
+
+		assertStatus(t, do(t, http.MethodPut, ts.URL+"/collections/fish/red", `{"type":"red"}`, nil), http.StatusNoContent)
+
+		expected := http.StatusMethodNotAllowed
+		if allow {
+			expected = http.StatusNoContent
+		}
+		assertStatus(t, do(t, http.MethodDelete, ts.URL+"/collections/fish", "", nil), expected)
+	}
+}
+
+// TestBodyTooLarge tests that request bodies over the limit are rejected.
+func TestBodyTooLarge(t *testing.T) {
+	ts := newTestServer(t, &Options{MaxBodyBytes: 16})
+	url := ts.URL + "/collections/fish/red"
+
+	assertStatus(t, do(t, http.MethodPut, url, `{"type":"red"}`, nil), http.StatusNoContent)
+	assertStatus(t, do(t, http.MethodPut, url, `{"type":"a much longer red"}`, nil), http.StatusRequestEntityTooLarge)
+
+	res := do(t, http.MethodGet, url, "", nil)
+	if b, _ := io.ReadAll(res.Body); string(b) != `{"type":"red"}` {
+		t.Error("Expected the record to be left alone, got: ", string(b))
+	}
+}
@@ -0,0 +1,95 @@
+package scribble
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// WriteRaw writes the given JSON to a resource within a collection exactly as
+// given, without unmarshaling and marshaling it again, so key order, number
+// formatting and whitespace are all preserved. It fails with ErrInvalidRecord
+// if b is not valid JSON.
+func (d *Driver) WriteRaw(collection, resource string, b []byte) error {
+	return d.WriteRawContext(context.Background(), collection, resource, b)
+}
+
+// WriteRawContext is like WriteRaw, but gives up waiting for the collection
+// lock and returns the context's error once ctx is done.
+func (d *Driver) WriteRawContext(ctx context.Context, collection, resource string, b []byte) (err error) {
+	op := d.begin(ctx, "write_raw", collection, resource)
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return errors.ErrClosed
+	}
+
+	if d.readOnly {
+		return errors.ErrReadOnly
+	}
+
+	if collection == "" {
+		return errors.ErrMissingCollection
+	}
+
+	if resource == "" {
+		return errors.ErrMissingResource
+	}
+
+	if err := checkPath(collection, resource); err != nil {
+		return err
+	}
+
+	if !json.Valid(b) {
+		return errors.ErrInvalidRecord
+	}
+
+	mutex := d.getOrCreateLock(collection)
+	if err := op.lock(mutex); err != nil {
+		return err
+	}
+	defer mutex.Unlock()
+
+	dir := filepath.Join(d.dir, collection)
+
+	op.bytes, err = d.writeRaw(collection, dir, resource, b)
+	return err
+}
+
+// ReadRaw returns the JSON of a resource within a collection exactly as it
+// was stored, without unmarshaling it.
+func (d *Driver) ReadRaw(collection, resource string) ([]byte, error) {
+	return d.ReadRawContext(context.Background(), collection, resource)
+}
+
+// ReadRawContext is like ReadRaw, but returns the context's error without
+// reading if ctx is already done.
+func (d *Driver) ReadRawContext(ctx context.Context, collection, resource string) (record []byte, err error) {
+	op := d.begin(ctx, "read_raw", collection, resource)
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return nil, errors.ErrClosed
+	}
+
+	if collection == "" {
+		return nil, errors.ErrMissingCollection
+	}
+
+	if resource == "" {
+		return nil, errors.ErrMissingResource
+	}
+
+	if err := checkPath(collection, resource); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	record, op.bytes, err = d.readRaw(collection, resource)
+	return record, err
+}
@@ -0,0 +1,85 @@
+package scribble
+
+import (
+	stderrors "errors"
+	"strings"
+	"testing"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// TestRaw tests that raw records are stored and read back byte for byte.
+func TestRaw(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{Compression: &Compression{}})
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	raw := `{"type":"red", "weight":1.50, "a":[1e3]}`
+
+	if err := d.WriteRaw(collection, "red", []byte(raw)); err != nil {
+		t.Fatal("WriteRaw failed: ", err.Error())
+	}
+
+	b, err := d.ReadRaw(collection, "red")
+	if err != nil {
+		t.Fatal("ReadRaw failed: ", err.Error())
+	}
+
+	if string(b) != raw {
+		t.Error("Expected the record unchanged, got: ", string(b))
+	}
+
+	if err := d.Read(collection, "red", &onefish); err != nil || onefish != redfish {
+		t.Error("Expected redfish, got: ", onefish, err)
+	}
+
+	if err := d.WriteRaw(collection, "blue", []byte(`{"type":`)); !stderrors.Is(err, errors.ErrInvalidRecord) {
+		t.Error("Expected ErrInvalidRecord, got: ", err)
+	}
+
+	if _, err := d.ReadRaw(collection, "blue"); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected ErrNotFound, got: ", err)
+	}
+}
+
+// TestRawRevertImport tests that reverted and imported records are stored
+// verbatim, like those written with WriteRaw.
+func TestRawRevertImport(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{History: &History{}})
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	raw := `{"type":"red","weight":1.50}`
+
+	if err := d.WriteRaw(collection, "red", []byte(raw)); err != nil {
+		t.Fatal("WriteRaw failed: ", err.Error())
+	}
+
+	if err := d.Write(collection, "red", bluefish); err != nil {
+		t.Fatal("Write failed: ", err.Error())
+	}
+
+	versions, err := d.History(collection, "red")
+	if err != nil || len(versions) != 1 {
+		t.Fatal("Expected one previous version, got: ", versions, err)
+	}
+
+	if err := d.Revert(collection, "red", versions[0].Rev); err != nil {
+		t.Fatal("Revert failed: ", err.Error())
+	}
+
+	if b, err := d.ReadRaw(collection, "red"); err != nil || string(b) != raw {
+		t.Error("Expected the reverted record unchanged, got: ", string(b), err)
+	}
+
+	line := `{"id":"blue","data":{"type":"blue","weight":2.50}}` + "\n"
+	if _, err := d.Import(collection, strings.NewReader(line), nil); err != nil {
+		t.Fatal("Import failed: ", err.Error())
+	}
+
+	if b, err := d.ReadRaw(collection, "blue"); err != nil || string(b) != `{"type":"blue","weight":2.50}` {
+		t.Error("Expected the imported record unchanged, got: ", string(b), err)
+	}
+}
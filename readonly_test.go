@@ -0,0 +1,81 @@
+package scribble
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// TestReadOnly tests that a read-only driver reads but never modifies the database.
+func TestReadOnly(t *testing.T) {
+	dir := t.TempDir()
+
+	rw, err := New(dir, nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := rw.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	d, err := New(dir, &Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal("Failed to open database: ", err.Error())
+	}
+
+	if err := d.Read(collection, "red", &onefish); err != nil || onefish != redfish {
+		t.Error("Expected redfish, got: ", onefish, err)
+	}
+
+	if err := d.Export(collection, &strings.Builder{}); err != nil {
+		t.Error("Expected export to work, got: ", err)
+	}
+
+	if err := d.Write(collection, "blue", bluefish); !stderrors.Is(err, errors.ErrReadOnly) {
+		t.Error("Expected ErrReadOnly writing, got: ", err)
+	}
+
+	if err := d.Delete(collection, "red"); !stderrors.Is(err, errors.ErrReadOnly) {
+		t.Error("Expected ErrReadOnly deleting, got: ", err)
+	}
+
+	if err := d.DropCollection(collection); !stderrors.Is(err, errors.ErrReadOnly) {
+		t.Error("Expected ErrReadOnly dropping, got: ", err)
+	}
+
+	if _, err := d.Check(&CheckOptions{Repair: true}); !stderrors.Is(err, errors.ErrReadOnly) {
+		t.Error("Expected ErrReadOnly repairing, got: ", err)
+	}
+
+	d.resourceLocks.Range(func(key, _ interface{}) bool {
+		t.Error("Expected no locks, got one for: ", key)
+		return true
+	})
+}
+
+// TestReadOnlyMissing tests that a read-only driver never creates the database.
+func TestReadOnlyMissing(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+
+	if _, err := New(dir, &Options{ReadOnly: true}); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected ErrNotFound, got: ", err)
+	}
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("Expected no directory to be created, got: ", err)
+	}
+
+	legacy := t.TempDir()
+	if _, err := New(legacy, &Options{ReadOnly: true}); err != nil {
+		t.Fatal("Failed to open database: ", err.Error())
+	}
+
+	if _, err := os.Stat(filepath.Join(legacy, ManifestFile)); !os.IsNotExist(err) {
+		t.Error("Expected no manifest to be written, got: ", err)
+	}
+}
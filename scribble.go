@@ -2,15 +2,21 @@
 package scribble
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/D7682/scribble/pkg/errors"
-	"github.com/jcelliott/lumber"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
-// Logger defines the interface for logging methods.
+// Logger defines the interface for printf-style logging methods, as provided
+// by loggers such as lumber. Prefer Options.Slog for structured logging.
 type Logger interface {
 	Fatal(string, ...interface{})
 	Error(string, ...interface{})
@@ -20,21 +26,92 @@ type Logger interface {
 	Trace(string, ...interface{})
 }
 
+// Store is the set of record operations shared by every scribble backend.
+// Code written against Store works unchanged with a local Driver or with a
+// remote client talking to a scribble HTTP server.
+type Store interface {
+	Write(collection, resource string, v interface{}) error
+	Read(collection, resource string, v interface{}) error
+	ReadAll(collection string) ([][]byte, error)
+	Delete(collection, resource string) error
+	DropCollection(collection string) error
+}
+
+// Driver implements Store.
+var _ Store = (*Driver)(nil)
+
 // Driver represents the main struct for interacting with the scribble database.
 type Driver struct {
-	mutex         sync.RWMutex
-	resourceLocks sync.Map
-	dir           string
-	log           Logger
+	mutex           sync.RWMutex // mutex is held shared by operations in flight, and taken exclusively once closed to wait for them
+	closed          atomic.Bool
+	closing         sync.Once
+	drained         chan struct{} // drained is closed once the driver is closed and no operation is in flight
+	resourceLocks   sync.Map
+	dir             string
+	log             *slog.Logger
+	instrumentation Instrumentation
+	encryption      *Encryption
+	compression     *Compression
+	history         *History
+	sharding        *Sharding
+	softDelete      bool
+	readOnly        bool
+	manifest        Manifest
+	manifestMutex   sync.Mutex
+	manifestPending bool // manifestPending is set until the manifest of a database opened without one is written
 }
 
 // Options represents the optional configurations for the scribble driver.
 type Options struct {
+	// Logger receives each log record formatted as a single line. It is used
+	// only when Slog is nil.
 	Logger
+
+	// Slog receives a structured record for every operation, with the
+	// collection, resource, duration and byte count as attributes. Successful
+	// operations are logged at debug level and failures at warn level. If
+	// neither Slog nor Logger is set, the driver does not log at all.
+	Slog *slog.Logger
+
+	// Instrumentation, if set, is told about every operation, including its
+	// duration, bytes transferred, errors and time spent waiting for locks.
+	Instrumentation Instrumentation
+
+	// Encryption, if set, encrypts records at rest with AES-GCM.
+	Encryption *Encryption
+
+	// Compression, if set, compresses records with gzip.
+	Compression *Compression
+
+	// History, if set, keeps previous versions of records when they are
+	// overwritten or deleted.
+	History *History
+
+	// Sharding, if set, spreads records over nested directories named by a
+	// hash of their IDs.
+	Sharding *Sharding
+
+	// SoftDelete makes Delete move records and collections into the Trash,
+	// from where they can be restored, instead of removing them.
+	SoftDelete bool
+
+	// ReadOnly opens an existing database without ever modifying it: New
+	// does not create the directory or the manifest, every operation that
+	// would change the database fails with ErrReadOnly, and collections are
+	// not locked.
+	ReadOnly bool
+
+	// Reconfigure accepts Compression, Encryption and Sharding settings that
+	// differ from those the database was created with, and records them in
+	// its manifest. Existing records stay readable; Rekey and MigrateLayout
+	// convert them to the new settings.
+	Reconfigure bool
 }
 
 // New creates a new scribble database driver instance.
-// It initializes a new database if it does not already exist.
+// It initializes a new database if it does not already exist, recording its
+// storage settings in a manifest. Opening an existing database with options
+// that conflict with its manifest fails with ErrIncompatible.
 func New(dir string, options *Options) (*Driver, error) {
 	dir = filepath.Clean(dir)
 
@@ -44,166 +121,660 @@ func New(dir string, options *Options) (*Driver, error) {
 		opts = *options
 	}
 
-	if opts.Logger == nil {
-		opts.Logger = lumber.NewConsoleLogger(lumber.INFO)
+	log := opts.Slog
+	switch {
+	case log != nil:
+	case opts.Logger != nil:
+		log = slog.New(&loggerHandler{log: opts.Logger})
+	default:
+		log = slog.New(discardHandler{})
 	}
 
 	driver := Driver{
-		dir:           dir,
-		resourceLocks: sync.Map{},
-		log:           opts.Logger,
+		dir:             dir,
+		resourceLocks:   sync.Map{},
+		log:             log,
+		instrumentation: opts.Instrumentation,
+		encryption:      opts.Encryption,
+		compression:     opts.Compression,
+		history:         opts.History,
+		sharding:        opts.Sharding,
+		softDelete:      opts.SoftDelete,
+		readOnly:        opts.ReadOnly,
 	}
 
+	created := false
 	if _, err := os.Stat(dir); err == nil {
-		opts.Logger.Debug("Using '%s' (database already exists)\n", dir)
-		return &driver, nil
+		log.Debug("using existing database", slog.String("dir", dir))
+	} else if opts.ReadOnly {
+		return nil, errors.NewPathError(dir, err)
+	} else {
+		log.Debug("creating database", slog.String("dir", dir))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return &driver, err
+		}
+		created = true
+	}
+
+	if err := driver.openManifest(opts.Reconfigure, created); err != nil {
+		return nil, err
 	}
 
-	opts.Logger.Debug("Creating scribble database at '%s'...\n", dir)
-	return &driver, os.MkdirAll(dir, 0755)
+	return &driver, nil
 }
 
 // Write writes the given data to a resource within a collection in the scribble database.
 func (d *Driver) Write(collection, resource string, v interface{}) error {
+	return d.WriteContext(context.Background(), collection, resource, v)
+}
+
+// WriteContext is like Write, but gives up waiting for the collection lock
+// and returns the context's error once ctx is done.
+func (d *Driver) WriteContext(ctx context.Context, collection, resource string, v interface{}) (err error) {
+	op := d.begin(ctx, "write", collection, resource)
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return errors.ErrClosed
+	}
+
+	if d.readOnly {
+		return errors.ErrReadOnly
+	}
+
 	if collection == "" {
 		return errors.ErrMissingCollection
 	}
 
 	if resource == "" {
-		return errors.ErrResourceNotFound
+		return errors.ErrMissingResource
+	}
+
+	if err := checkPath(collection, resource); err != nil {
+		return err
 	}
 
 	mutex := d.getOrCreateLock(collection)
-	mutex.Lock()
+	if err := op.lock(mutex); err != nil {
+		return err
+	}
 	defer mutex.Unlock()
 
 	dir := filepath.Join(d.dir, collection)
-	fnlPath := filepath.Join(dir, resource+".json")
-	tmpPath := fnlPath + ".tmp"
 
-	return write(dir, tmpPath, fnlPath, v)
+	op.bytes, err = d.write(collection, dir, resource, v)
+	return err
 }
 
-// write is a helper function for writing data to a file.
-func write(dir, tmpPath, dstPath string, v interface{}) error {
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return errors.NewFileIOError(dir, err)
+// UpdateFunc receives the current contents of a record, or nil if the record
+// does not exist yet, and returns the value to store in its place. A returned
+// json.RawMessage is stored exactly as given, like WriteRaw does.
+type UpdateFunc func(raw json.RawMessage) (interface{}, error)
+
+// Update atomically reads, modifies and writes a resource within a collection.
+// The collection lock is held across the read and the write, so concurrent
+// updates to the same collection cannot lose each other's changes. If fn
+// returns an error the record is left untouched and the error is returned.
+func (d *Driver) Update(collection, resource string, fn UpdateFunc) error {
+	return d.UpdateContext(context.Background(), collection, resource, fn)
+}
+
+// UpdateContext is like Update, but gives up waiting for the collection lock
+// and returns the context's error once ctx is done.
+func (d *Driver) UpdateContext(ctx context.Context, collection, resource string, fn UpdateFunc) (err error) {
+	op := d.begin(ctx, "update", collection, resource)
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return errors.ErrClosed
 	}
 
-	b, err := json.MarshalIndent(v, "", "\t")
+	if d.readOnly {
+		return errors.ErrReadOnly
+	}
+
+	if collection == "" {
+		return errors.ErrMissingCollection
+	}
+
+	if resource == "" {
+		return errors.ErrMissingResource
+	}
+
+	if err := checkPath(collection, resource); err != nil {
+		return err
+	}
+
+	mutex := d.getOrCreateLock(collection)
+	if err := op.lock(mutex); err != nil {
+		return err
+	}
+	defer mutex.Unlock()
+
+	dir := filepath.Join(d.dir, collection)
+
+	var b []byte
+	record, err := d.findRecord(collection, dir, resource)
+	if err == nil {
+		b, _, err = d.readRecord(record)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return errors.NewPathError(record, err)
+	}
+
+	v, err := fn(b)
 	if err != nil {
 		return err
 	}
 
+	if raw, ok := v.(json.RawMessage); ok {
+		if !json.Valid(raw) {
+			return errors.ErrInvalidRecord
+		}
+
+		op.bytes, err = d.writeRaw(collection, dir, resource, raw)
+		return err
+	}
+
+	op.bytes, err = d.write(collection, dir, resource, v)
+	return err
+}
+
+// UpdateAs is a typed wrapper around Driver.Update. The current record is
+// unmarshaled into a T (left as its zero value if the record does not exist),
+// passed to fn for modification, and written back.
+func UpdateAs[T any](d *Driver, collection, resource string, fn func(*T) error) error {
+	return d.Update(collection, resource, func(raw json.RawMessage) (interface{}, error) {
+		var v T
+		if raw != nil {
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := fn(&v); err != nil {
+			return nil, err
+		}
+
+		return &v, nil
+	})
+}
+
+// Insert writes the given data to a new resource within a collection, using a
+// freshly generated ID as the resource name. IDs are ULIDs, so they are unique
+// and sort in creation order. The generated ID is returned.
+func (d *Driver) Insert(collection string, v interface{}) (string, error) {
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+
+	if err := d.Write(collection, id, v); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// write is a helper function for writing data to a record file in a
+// collection, encoded as configured for that collection. It returns the
+// number of bytes written.
+func (d *Driver) write(collection, dir, resource string, v interface{}) (int, error) {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return 0, err
+	}
+
 	b = append(b, byte('\n'))
 
+	return d.writeRaw(collection, dir, resource, b)
+}
+
+// writeRaw is a helper function for writing the JSON of a record, exactly as
+// given, to a record file in a collection. The current version of the record
+// is archived first. It returns the number of bytes written.
+func (d *Driver) writeRaw(collection, dir, resource string, b []byte) (int, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, errors.NewFileIOError(dir, err)
+	}
+
+	if err := d.archive(collection, dir, resource); err != nil {
+		return 0, err
+	}
+
+	return d.writeRecord(collection, dir, resource, b)
+}
+
+// writeBytes is a helper function for atomically writing raw bytes to a file
+// by way of a temporary file and a rename.
+func writeBytes(dir, tmpPath, dstPath string, b []byte) error {
 	if err := os.WriteFile(tmpPath, b, 0644); err != nil {
-		return errors.NewFileIOError(dir, err)
+		return errors.NewFileIOError(tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		return errors.NewFileIOError(dstPath, err)
 	}
 
-	return os.Rename(tmpPath, dstPath)
+	return nil
 }
 
 // Read reads data from a resource within a collection in the scribble database.
 func (d *Driver) Read(collection, resource string, v interface{}) error {
+	return d.ReadContext(context.Background(), collection, resource, v)
+}
+
+// ReadContext is like Read, but returns the context's error without reading
+// if ctx is already done.
+func (d *Driver) ReadContext(ctx context.Context, collection, resource string, v interface{}) (err error) {
+	op := d.begin(ctx, "read", collection, resource)
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return errors.ErrClosed
+	}
+
 	if collection == "" {
 		return errors.ErrMissingCollection
 	}
 
 	if resource == "" {
-		return errors.ErrResourceNotFound
+		return errors.ErrMissingResource
+	}
+
+	if err := checkPath(collection, resource); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	op.bytes, err = d.read(collection, resource, v)
+	return err
+}
+
+// read is a helper function for reading data from a record file, whichever
+// extension it was stored with. It returns the number of bytes read.
+func (d *Driver) read(collection, resource string, v interface{}) (int, error) {
+	b, n, err := d.readRaw(collection, resource)
+	if err != nil {
+		return n, err
 	}
 
-	record := filepath.Join(d.dir, collection, resource)
-	return read(record, v)
+	return n, json.Unmarshal(b, v)
 }
 
-// read is a helper function for reading data from a file.
-func read(record string, v interface{}) error {
-	b, err := os.ReadFile(record + ".json")
+// readRaw is a helper function for reading the JSON of a record, exactly as it
+// was stored. It also returns the number of bytes read.
+func (d *Driver) readRaw(collection, resource string) ([]byte, int, error) {
+	record, err := d.findRecord(collection, filepath.Join(d.dir, collection), resource)
 	if err != nil {
-		return errors.NewFileIOError(record+".json", err)
+		return nil, 0, errors.NewPathError(record, err)
 	}
 
-	return json.Unmarshal(b, v)
+	b, n, err := d.readRecord(record)
+	if err != nil {
+		return nil, n, errors.NewPathError(record, err)
+	}
+
+	return b, n, nil
 }
 
 // ReadAll retrieves all records from a collection in the scribble database.
 func (d *Driver) ReadAll(collection string) ([][]byte, error) {
+	return d.ReadAllContext(context.Background(), collection)
+}
+
+// ReadAllContext is like ReadAll, but checks ctx before reading each file and
+// returns the context's error, discarding partial results, once it is done.
+func (d *Driver) ReadAllContext(ctx context.Context, collection string) (records [][]byte, err error) {
+	op := d.begin(ctx, "read_all", collection, "")
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return nil, errors.ErrClosed
+	}
+
 	if collection == "" {
 		return nil, errors.ErrMissingCollection
 	}
 
+	if err := checkPath(collection, ""); err != nil {
+		return nil, err
+	}
+
 	dir := filepath.Join(d.dir, collection)
-	files, err := os.ReadDir(dir)
+	ids, paths, err := recordFiles(dir)
 	if err != nil {
-		return nil, errors.NewFileIOError(dir, err)
+		return nil, errors.NewPathError(dir, err)
 	}
 
-	return readAll(files, dir)
+	records, op.bytes, err = d.readAll(ctx, ids, paths)
+	return records, err
 }
 
-// readAll is a helper function for reading all records from a collection.
-func readAll(files []os.DirEntry, dir string) ([][]byte, error) {
+// readAll is a helper function for reading the records with the given IDs,
+// in order, from the files holding them. It also returns the number of bytes
+// read.
+func (d *Driver) readAll(ctx context.Context, ids []string, paths map[string]string) ([][]byte, int, error) {
 	var records [][]byte
+	total := 0
 
-	for _, file := range files {
-		b, err := os.ReadFile(filepath.Join(dir, file.Name()))
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, total, err
+		}
+
+		b, n, err := d.readRecord(paths[id])
+		total += n
 		if err != nil {
-			return nil, errors.NewFileIOError(filepath.Join(dir, file.Name()), err)
+			return nil, total, errors.NewPathError(paths[id], err)
 		}
 		records = append(records, b)
 	}
 
-	return records, nil
+	return records, total, nil
+}
+
+// Collections returns the names of every collection in the database, including
+// nested sub-collections, as slash-separated paths relative to the root. The
+// shard and history directories inside collections are not collections and
+// are left out, as are the Trash and the LostAndFound, which ListTrash and
+// Check expose instead.
+func (d *Driver) Collections() (collections []string, err error) {
+	op := d.begin(context.Background(), "collections", "", "")
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return nil, errors.ErrClosed
+	}
+
+	all, err := d.collections()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, collection := range all {
+		if !internal(collection) {
+			collections = append(collections, collection)
+		}
+	}
+
+	return collections, nil
+}
+
+// collections is a helper function for listing collections on behalf of an
+// operation already in flight, which Close waits for rather than interrupts.
+// Unlike Collections, it includes the Trash and the LostAndFound.
+func (d *Driver) collections() ([]string, error) {
+	var collections []string
+
+	err := filepath.WalkDir(d.dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() || p == d.dir {
+			return nil
+		}
+
+		if filepath.Dir(p) != d.dir && internalDir(entry.Name()) {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(d.dir, p)
+		if err != nil {
+			return err
+		}
+
+		collections = append(collections, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.NewFileIOError(d.dir, err)
+	}
+
+	return collections, nil
+}
+
+// List returns the IDs of all records in a collection, in lexical order.
+func (d *Driver) List(collection string) (ids []string, err error) {
+	op := d.begin(context.Background(), "list", collection, "")
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return nil, errors.ErrClosed
+	}
+
+	if collection == "" {
+		return nil, errors.ErrMissingCollection
+	}
+
+	if err := checkPath(collection, ""); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(d.dir, collection)
+	ids, err = resources(dir)
+	if err != nil {
+		return nil, errors.NewPathError(dir, err)
+	}
+
+	return ids, nil
+}
+
+// resources is a helper function for listing the IDs of the records stored
+// in a collection directory, in either layout, in lexical order.
+// Sub-collections and temporary files are skipped.
+func resources(dir string) ([]string, error) {
+	ids, _, err := recordFiles(dir)
+	return ids, err
 }
 
 // Delete removes a resource within a collection from the scribble database.
+// It never removes collections; use DropCollection for that.
 func (d *Driver) Delete(collection, resource string) error {
-	path := filepath.Join(collection, resource)
-	mutex := d.getOrCreateLock(collection)
-	mutex.Lock()
-	defer mutex.Unlock()
+	return d.DeleteContext(context.Background(), collection, resource)
+}
 
-	dir := filepath.Join(d.dir, path)
-	fi, err := stat(dir)
+// DeleteContext is like Delete, but gives up waiting for the collection lock
+// and returns the context's error once ctx is done.
+func (d *Driver) DeleteContext(ctx context.Context, collection, resource string) (err error) {
+	op := d.begin(ctx, "delete", collection, resource)
+	defer op.end(&err)
 
-	if fi == nil || err != nil {
-		return errors.NewNotFoundError(path, os.ErrNotExist)
+	if d.closed.Load() {
+		return errors.ErrClosed
 	}
 
-	switch {
-	case fi.Mode().IsDir():
-		if err := os.RemoveAll(dir); err != nil {
-			return errors.NewFileIOError(dir, err)
+	if d.readOnly {
+		return errors.ErrReadOnly
+	}
+
+	if collection == "" {
+		return errors.ErrMissingCollection
+	}
+
+	if resource == "" {
+		return errors.ErrMissingResource
+	}
+
+	if err := checkPath(collection, resource); err != nil {
+		return err
+	}
+
+	// Soft-deleted records go into the Trash, so it is locked as well.
+	locked := []string{collection}
+	if d.softDelete {
+		locked = append(locked, Trash)
+	}
+
+	unlock, err := d.lockCollections(op, locked...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	dir := filepath.Join(d.dir, collection)
+	record, err := d.findRecord(collection, dir, resource)
+	if os.IsNotExist(err) {
+		if fi, err := os.Stat(filepath.Join(dir, resource)); err == nil && fi.IsDir() {
+			return errors.ErrIsCollection
 		}
-	case fi.Mode().IsRegular():
-		if err := os.RemoveAll(dir + ".json"); err != nil {
-			return errors.NewFileIOError(dir+".json", err)
+		return errors.NewNotFoundError(filepath.Join(collection, resource), os.ErrNotExist)
+	} else if err != nil {
+		return errors.NewFileIOError(record, err)
+	}
+
+	if err := d.archive(collection, dir, resource); err != nil {
+		return err
+	}
+
+	if d.softDelete {
+		return d.trash(collection, resource, record)
+	}
+
+	return d.removeRecord(collection, dir, resource)
+}
+
+// DropCollection removes a whole collection, including its records, its
+// history and any sub-collections, from the scribble database. The collection
+// and every one of its sub-collections are locked while it is removed.
+func (d *Driver) DropCollection(collection string) error {
+	return d.DropCollectionContext(context.Background(), collection)
+}
+
+// DropCollectionContext is like DropCollection, but gives up waiting for the
+// collection locks and returns the context's error once ctx is done.
+func (d *Driver) DropCollectionContext(ctx context.Context, collection string) (err error) {
+	op := d.begin(ctx, "drop_collection", collection, "")
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return errors.ErrClosed
+	}
+
+	if d.readOnly {
+		return errors.ErrReadOnly
+	}
+
+	if collection == "" {
+		return errors.ErrMissingCollection
+	}
+
+	if err := checkPath(collection, ""); err != nil {
+		return err
+	}
+
+	// Soft-deleted collections go into the Trash, so it is locked as well.
+	var others []string
+	if d.softDelete {
+		others = append(others, Trash)
+	}
+
+	unlock, err := d.lockCollectionTree(op, collection, others...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	dir := filepath.Join(d.dir, collection)
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return errors.NewNotFoundError(collection, os.ErrNotExist)
+	}
+
+	if d.softDelete {
+		return d.trash(collection, "", dir)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return errors.NewFileIOError(dir, err)
+	}
+
+	return nil
+}
+
+// checkPath is a helper function for validating that a collection, and a
+// resource within it if given, resolve to a path inside the database root.
+// Names may contain slashes for sub-collections but may not climb out of
+// their parent with "..", be absolute, or refer to the parent itself. They
+// must also be in canonical form, without empty, "." or ".." elements or a
+// trailing slash, since collections are locked by name and every spelling of
+// a directory has to share its lock. No element of a collection may be a name
+// the driver keeps for itself, and resources may not contain slashes at all:
+// a record belongs directly to its collection.
+func checkPath(collection, resource string) error {
+	if !isLocal(collection) || (resource != "" && (!isLocal(resource) || strings.Contains(resource, "/"))) {
+		return errors.ErrInvalidPath
+	}
+
+	for _, name := range strings.Split(collection, "/") {
+		if internalDir(name) || internal(name) {
+			return errors.ErrInvalidPath
 		}
 	}
 
 	return nil
 }
 
-// stat is a helper function for obtaining file information.
-func stat(path string) (fi os.FileInfo, err error) {
-	if fi, err = os.Stat(path); os.IsNotExist(err) {
-		fi, err = os.Stat(path + ".json")
+// isLocal is a helper function for reporting whether a name is a canonical
+// relative path that stays strictly below the directory it is joined to.
+func isLocal(name string) bool {
+	return filepath.IsLocal(name) && name != "." && path.Clean(name) == name
+}
+
+// Close shuts the driver down. Operations started after Close fail with
+// ErrClosed; Close waits for those already in flight to finish, or returns
+// the context's error if it is done first, in which case it may be called
+// again. Once they have finished, the driver's collection locks are released.
+// Closing a closed driver does nothing.
+func (d *Driver) Close(ctx context.Context) error {
+	d.closed.Store(true)
+
+	// Operations that begin from now on see that the driver is closed, so
+	// once the lifecycle lock has been taken nothing else is in flight.
+	d.closing.Do(func() {
+		d.drained = make(chan struct{})
+		go func() {
+			d.mutex.Lock()
+			close(d.drained)
+			d.mutex.Unlock()
+		}()
+	})
+
+	select {
+	case <-d.drained:
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return
+
+	d.resourceLocks.Range(func(collection, _ interface{}) bool {
+		d.resourceLocks.Delete(collection)
+		return true
+	})
+
+	d.log.Debug("closed database", slog.String("dir", d.dir))
+
+	return nil
 }
 
 // getOrCreateLock retrieves or creates a lock for a collection to ensure thread safety.
-func (d *Driver) getOrCreateLock(collection string) *sync.Mutex {
-	// Load or store a new lock for the collection
-	l, loaded := d.resourceLocks.LoadOrStore(collection, &sync.Mutex{})
+// Writers take the lock exclusively; operations that only need a stable view
+// of a collection, such as backups, take it shared. A read-only driver has
+// nothing to protect, so it returns a nil lock, which is never held.
+func (d *Driver) getOrCreateLock(collection string) *rwLock {
+	if d.readOnly {
+		return nil
+	}
 
-	// If the lock was not loaded, it means a new one was stored, and we need to unlock
-	// the main mutex to avoid potential contention.
-	if !loaded {
-		d.mutex.Unlock()
+	if l, ok := d.resourceLocks.Load(collection); ok {
+		return l.(*rwLock)
 	}
 
-	return l.(*sync.Mutex)
+	// Load or store a new lock for the collection. LoadOrStore is atomic, so
+	// concurrent callers always end up sharing the same lock.
+	l, _ := d.resourceLocks.LoadOrStore(collection, newRWLock())
+
+	return l.(*rwLock)
 }
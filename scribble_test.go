@@ -1,8 +1,19 @@
 package scribble
 
 import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/D7682/scribble/pkg/errors"
 )
 
 // Fish represents a fish with a type.
@@ -12,7 +23,7 @@ type Fish struct {
 
 var (
 	db         *Driver
-	database   = "./deep/school"
+	database   string
 	collection = "fish"
 	onefish    = Fish{}
 	twofish    = Fish{}
@@ -20,27 +31,10 @@ var (
 	bluefish   = Fish{Type: "blue"}
 )
 
-// TestMain sets up and runs tests, ensuring cleanup afterward.
-func TestMain(m *testing.M) {
-	setupAndRunTests(m)
-}
-
-// setupAndRunTests removes the test directory before and after running tests.
-func setupAndRunTests(m *testing.M) {
-	removeTestDir()
-	defer removeTestDir()
-
-	code := m.Run()
-
-	os.Exit(code)
-}
-
-// removeTestDir removes the test directory.
-func removeTestDir() {
-	err := os.RemoveAll("./deep")
-	if err != nil {
-		return
-	}
+// useTestDir points the database at a fresh directory, which is removed once
+// the test ends.
+func useTestDir(t *testing.T) {
+	database = filepath.Join(t.TempDir(), "school")
 }
 
 // createDB creates a new Scribble database.
@@ -52,8 +46,19 @@ func createDB() error {
 	return nil
 }
 
+// newTestDriver creates a database in dir with the given options, failing the
+// test if it cannot.
+func newTestDriver(t *testing.T, dir string, options *Options) *Driver {
+	d, err := New(dir, options)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+	return d
+}
+
 // TestNew tests the creation of a new database.
 func TestNew(t *testing.T) {
+	useTestDir(t)
 	assertDatabaseNotExists(t)
 
 	err := createDB()
@@ -87,6 +92,8 @@ func assertDatabaseExists(t *testing.T) {
 
 // TestWriteAndRead tests writing and reading fish from the database.
 func TestWriteAndRead(t *testing.T) {
+	useTestDir(t)
+
 	err := createDB()
 	if err != nil {
 		return
@@ -138,6 +145,8 @@ func assertFishCountNonZero(t *testing.T) {
 
 // TestReadall tests reading all fish from the database.
 func TestReadall(t *testing.T) {
+	useTestDir(t)
+
 	err := createDB()
 	if err != nil {
 		return
@@ -170,13 +179,15 @@ func readAllFishFromDatabase(t *testing.T) {
 
 // TestWriteAndReadEmpty tests writing and reading empty fish to/from the database.
 func TestWriteAndReadEmpty(t *testing.T) {
+	useTestDir(t)
+
 	err := createDB()
 	if err != nil {
 		return
 	}
 
-	writeEmptyFishToDatabase(t, "redfish", redfish)
-	writeEmptyFishToDatabase(t, "", redfish)
+	writeEmptyFishToDatabase(t, collection, "", redfish)
+	writeEmptyFishToDatabase(t, "", "redfish", redfish)
 
 	readEmptyFishFromDatabase(t, "redfish")
 
@@ -187,21 +198,181 @@ func TestWriteAndReadEmpty(t *testing.T) {
 }
 
 // writeEmptyFishToDatabase attempts to write empty fish to the database, expecting an error.
-func writeEmptyFishToDatabase(t *testing.T, key string, fish Fish) {
-	if err := db.Write(key, "redfish", fish); err == nil {
-		t.Error("Allowed write of empty resource", err.Error())
+func writeEmptyFishToDatabase(t *testing.T, coll, key string, fish Fish) {
+	if err := db.Write(coll, key, fish); err == nil {
+		t.Error("Allowed write of empty resource")
 	}
 }
 
 // readEmptyFishFromDatabase attempts to read empty fish from the database, expecting an error.
 func readEmptyFishFromDatabase(t *testing.T, key string) {
 	if err := db.Read("", key, onefish); err == nil {
-		t.Error("Allowed read of empty resource", err.Error())
+		t.Error("Allowed read of empty resource")
+	}
+}
+
+// TestInsert tests inserting fish under generated IDs.
+func TestInsert(t *testing.T) {
+	useTestDir(t)
+
+	err := createDB()
+	if err != nil {
+		return
+	}
+
+	first := insertFishIntoDatabase(t, redfish)
+	second := insertFishIntoDatabase(t, bluefish)
+
+	if first == second {
+		t.Error("Expected unique IDs, got: ", first)
+	}
+
+	if first >= second {
+		t.Errorf("Expected IDs to sort by creation, got %s before %s", first, second)
+	}
+
+	readFishFromDatabase(t, first)
+	assertFishType(t, "red")
+
+	err = destroySchool()
+	if err != nil {
+		return
+	}
+}
+
+// insertFishIntoDatabase inserts a fish into the database and returns its ID.
+func insertFishIntoDatabase(t *testing.T, fish Fish) string {
+	id, err := db.Insert(collection, fish)
+	if err != nil {
+		t.Error("Insert fish failed: ", err.Error())
+	}
+	return id
+}
+
+// Counter is a record used to exercise concurrent updates.
+type Counter struct {
+	N int `json:"n"`
+}
+
+// TestUpdate tests that concurrent updates to a record do not lose writes.
+func TestUpdate(t *testing.T) {
+	useTestDir(t)
+
+	err := createDB()
+	if err != nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			incrementCounter(t)
+		}()
+	}
+	wg.Wait()
+
+	assertCounter(t, 50)
+
+	err = db.DropCollection("counters")
+	if err != nil {
+		return
+	}
+}
+
+// incrementCounter increments the shared counter record by one.
+func incrementCounter(t *testing.T) {
+	err := db.Update("counters", "hits", func(raw json.RawMessage) (interface{}, error) {
+		c := Counter{}
+		if raw != nil {
+			if err := json.Unmarshal(raw, &c); err != nil {
+				return nil, err
+			}
+		}
+		c.N++
+		return c, nil
+	})
+	if err != nil {
+		t.Error("Failed to update: ", err.Error())
+	}
+}
+
+// assertCounter asserts that the shared counter record holds the expected value.
+func assertCounter(t *testing.T, expected int) {
+	c := Counter{}
+	if err := db.Read("counters", "hits", &c); err != nil {
+		t.Error("Failed to read: ", err.Error())
+	}
+
+	if c.N != expected {
+		t.Errorf("Expected counter %d, got: %d", expected, c.N)
+	}
+}
+
+// TestUpdateAs tests the typed update helper.
+func TestUpdateAs(t *testing.T) {
+	useTestDir(t)
+
+	err := createDB()
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < 3; i++ {
+		err := UpdateAs(db, "counters", "hits", func(c *Counter) error {
+			c.N += 2
+			return nil
+		})
+		if err != nil {
+			t.Error("Failed to update: ", err.Error())
+		}
+	}
+
+	assertCounter(t, 6)
+
+	err = db.DropCollection("counters")
+	if err != nil {
+		return
+	}
+}
+
+// TestErrors tests that failures can be matched with errors.Is and errors.As.
+func TestErrors(t *testing.T) {
+	useTestDir(t)
+
+	err := createDB()
+	if err != nil {
+		return
+	}
+
+	err = db.Read(collection, "nofish", &onefish)
+	if !stderrors.Is(err, errors.ErrNotFound) || !stderrors.Is(err, fs.ErrNotExist) {
+		t.Error("Expected read of a missing fish to be not found, got: ", err)
+	}
+
+	var se errors.ScribblerError
+	if !stderrors.As(err, &se) || se.Op() != "read" || se.Collection() != collection || se.Resource() != "nofish" {
+		t.Error("Expected error to carry the read context, got: ", err)
+	}
+
+	if err := db.Delete(collection, "nofish"); !stderrors.Is(err, os.ErrNotExist) {
+		t.Error("Expected delete of a missing fish to be not found, got: ", err)
+	}
+
+	if err := db.Write(collection, "", redfish); !stderrors.Is(err, errors.ErrMissingResource) {
+		t.Error("Expected write without a resource to be rejected, got: ", err)
+	}
+
+	if _, err := db.ReadAll("nocollection"); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected read of a missing collection to be not found, got: ", err)
 	}
 }
 
 // TestDelete tests deleting a fish from the database.
 func TestDelete(t *testing.T) {
+	useTestDir(t)
+
 	err := createDB()
 	if err != nil {
 		return
@@ -234,6 +405,8 @@ func readDeletedFishFromDatabase(t *testing.T, key string) {
 
 // TestDeleteall tests deleting all fish from the database.
 func TestDeleteall(t *testing.T) {
+	useTestDir(t)
+
 	err := createDB()
 	if err != nil {
 		return
@@ -254,7 +427,7 @@ func TestDeleteall(t *testing.T) {
 
 // deleteAllFishFromDatabase deletes all fish from the database.
 func deleteAllFishFromDatabase(t *testing.T) {
-	if err := db.Delete(collection, ""); err != nil {
+	if err := db.DropCollection(collection); err != nil {
 		t.Error("Failed to delete: ", err.Error())
 	}
 }
@@ -289,5 +462,157 @@ func destroyFish(name string) error {
 
 // destroySchool deletes all fish from the database.
 func destroySchool() error {
-	return db.Delete(collection, "")
+	return db.DropCollection(collection)
+}
+
+// TestDeleteCollection tests that Delete refuses to remove a collection.
+func TestDeleteCollection(t *testing.T) {
+	d, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := d.Write(collection+"/school", "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Delete(collection, "school"); !stderrors.Is(err, errors.ErrIsCollection) {
+		t.Error("Expected ErrIsCollection, got: ", err)
+	}
+
+	if err := d.Delete(collection, ""); !stderrors.Is(err, errors.ErrMissingResource) {
+		t.Error("Expected ErrMissingResource, got: ", err)
+	}
+
+	if err := d.Read(collection+"/school", "red", &onefish); err != nil {
+		t.Error("Expected the school to survive, got: ", err)
+	}
+
+	if err := d.DropCollection(collection); err != nil {
+		t.Fatal("Drop collection failed: ", err.Error())
+	}
+
+	if err := d.DropCollection(collection); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected ErrNotFound dropping a missing collection, got: ", err)
+	}
+}
+
+// TestDropCollectionLocksSubCollections tests that dropping a collection waits
+// for writers to its sub-collections.
+func TestDropCollectionLocksSubCollections(t *testing.T) {
+	d := newTestDriver(t, t.TempDir(), nil)
+
+	if err := d.Write(collection+"/school", "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	mutex := d.getOrCreateLock(collection + "/school")
+	mutex.Lock()
+
+	done := make(chan error)
+	go func() { done <- d.DropCollection(collection) }()
+
+	select {
+	case err := <-done:
+		t.Fatal("Expected the drop to wait for the sub-collection, got: ", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	mutex.Unlock()
+
+	if err := <-done; err != nil {
+		t.Fatal("DropCollection failed: ", err.Error())
+	}
+
+	if _, err := os.Stat(filepath.Join(d.dir, collection)); !os.IsNotExist(err) {
+		t.Error("Expected the collection to be gone, got: ", err)
+	}
+}
+
+// TestReservedPath tests that collections cannot use the names the driver
+// keeps for itself, and that resources cannot contain slashes.
+func TestReservedPath(t *testing.T) {
+	d := newTestDriver(t, t.TempDir(), nil)
+
+	for _, name := range []string{"_trash", "_lost+found/1", "_history", "fish/_history", "fish/_shards", "fish/_shards/ab"} {
+		if err := d.Write(name, "red", redfish); !stderrors.Is(err, errors.ErrInvalidPath) {
+			t.Errorf("Expected ErrInvalidPath writing into %q, got: %v", name, err)
+		}
+
+		if err := d.DropCollection(name); !stderrors.Is(err, errors.ErrInvalidPath) {
+			t.Errorf("Expected ErrInvalidPath dropping %q, got: %v", name, err)
+		}
+
+		if err := d.Backup(io.Discard, name); !stderrors.Is(err, errors.ErrInvalidPath) {
+			t.Errorf("Expected ErrInvalidPath backing up %q, got: %v", name, err)
+		}
+	}
+
+	if err := d.Write(collection, "school/red", redfish); !stderrors.Is(err, errors.ErrInvalidPath) {
+		t.Error("Expected ErrInvalidPath writing a resource with a slash, got: ", err)
+	}
+
+	if err := d.Move(collection, "red", collection, "school/red"); !stderrors.Is(err, errors.ErrInvalidPath) {
+		t.Error("Expected ErrInvalidPath moving to a resource with a slash, got: ", err)
+	}
+}
+
+// TestInvalidPath tests that names cannot escape the database root, and that
+// every name is in canonical form.
+func TestInvalidPath(t *testing.T) {
+	d, err := New(filepath.Join(t.TempDir(), "db"), nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	for _, name := range []string{"..", "../outside", ".", "fish/..", "/etc", "./fish", "fish/", "x/../fish", "fish//school"} {
+		if err := d.DropCollection(name); !stderrors.Is(err, errors.ErrInvalidPath) {
+			t.Errorf("Expected ErrInvalidPath dropping %q, got: %v", name, err)
+		}
+
+		if err := d.Write(collection, name, redfish); !stderrors.Is(err, errors.ErrInvalidPath) {
+			t.Errorf("Expected ErrInvalidPath writing %q, got: %v", name, err)
+		}
+
+		if _, err := d.List(name); !stderrors.Is(err, errors.ErrInvalidPath) {
+			t.Errorf("Expected ErrInvalidPath listing %q, got: %v", name, err)
+		}
+
+		if err := d.Export(name, io.Discard); !stderrors.Is(err, errors.ErrInvalidPath) {
+			t.Errorf("Expected ErrInvalidPath exporting %q, got: %v", name, err)
+		}
+
+		if err := d.Backup(io.Discard, name); !stderrors.Is(err, errors.ErrInvalidPath) {
+			t.Errorf("Expected ErrInvalidPath backing up %q, got: %v", name, err)
+		}
+
+		if err := d.Rekey(name); !stderrors.Is(err, errors.ErrInvalidPath) {
+			t.Errorf("Expected ErrInvalidPath rekeying %q, got: %v", name, err)
+		}
+
+		if _, err := d.History(collection, name); !stderrors.Is(err, errors.ErrInvalidPath) {
+			t.Errorf("Expected ErrInvalidPath listing history of %q, got: %v", name, err)
+		}
+
+		if err := d.ReadVersion(collection, name, "rev", &onefish); !stderrors.Is(err, errors.ErrInvalidPath) {
+			t.Errorf("Expected ErrInvalidPath reading a version of %q, got: %v", name, err)
+		}
+
+		if err := d.Revert(collection, name, "rev"); !stderrors.Is(err, errors.ErrInvalidPath) {
+			t.Errorf("Expected ErrInvalidPath reverting %q, got: %v", name, err)
+		}
+
+		line := fmt.Sprintf(`{"id":%q,"data":{"type":"red"}}`+"\n", name)
+		if _, err := d.Import(collection, strings.NewReader(line), nil); !stderrors.Is(err, errors.ErrInvalidPath) {
+			t.Errorf("Expected ErrInvalidPath importing %q, got: %v", name, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(d.dir), "outside.json")); !os.IsNotExist(err) {
+		t.Error("Expected nothing written outside the database, got: ", err)
+	}
+
+	if _, err := os.Stat(d.dir); err != nil {
+		t.Error("Expected the database to survive, got: ", err)
+	}
 }
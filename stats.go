@@ -0,0 +1,149 @@
+package scribble
+
+import (
+	"context"
+	stderrors "errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// Stats describes the records stored in a collection, or in the whole
+// database. Sizes are of the files on disk, after any compression and
+// encryption, and times are file modification times. Previous versions kept
+// by History are not counted.
+type Stats struct {
+	Records     int
+	Bytes       int64     // Bytes is the total size of every record
+	Largest     int64     // Largest is the size of the largest record
+	Oldest      time.Time // Oldest is when the least recently written record was written
+	Newest      time.Time // Newest is when the most recently written record was written
+	OrphanedTmp int       // OrphanedTmp is the number of temporary files left behind by interrupted writes
+}
+
+// add is a helper function for folding another set of statistics into s.
+func (s *Stats) add(o Stats) {
+	s.Records += o.Records
+	s.Bytes += o.Bytes
+	s.OrphanedTmp += o.OrphanedTmp
+
+	if o.Largest > s.Largest {
+		s.Largest = o.Largest
+	}
+
+	if !o.Oldest.IsZero() && (s.Oldest.IsZero() || o.Oldest.Before(s.Oldest)) {
+		s.Oldest = o.Oldest
+	}
+
+	if o.Newest.After(s.Newest) {
+		s.Newest = o.Newest
+	}
+}
+
+// Stats returns statistics about the records stored directly in a collection,
+// leaving out its sub-collections. The collection is locked while it is
+// measured, so writes in flight are never counted as orphaned.
+func (d *Driver) Stats(collection string) (stats Stats, err error) {
+	op := d.begin(context.Background(), "stats", collection, "")
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return stats, errors.ErrClosed
+	}
+
+	if collection == "" {
+		return stats, errors.ErrMissingCollection
+	}
+
+	if err := checkPath(collection, ""); err != nil {
+		return stats, err
+	}
+
+	return d.collectionStats(op, collection)
+}
+
+// DBStats returns statistics about the records stored in every collection in
+// the database, except the LostAndFound and the Trash. Collections are locked
+// and measured one at a time, so the result is not a snapshot of the database
+// at a single moment.
+func (d *Driver) DBStats() (stats Stats, err error) {
+	op := d.begin(context.Background(), "db_stats", "", "")
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return stats, errors.ErrClosed
+	}
+
+	collections, err := d.collections()
+	if err != nil {
+		return stats, err
+	}
+
+	for _, collection := range collections {
+		if internal(collection) {
+			continue
+		}
+
+		s, err := d.collectionStats(op, collection)
+		switch {
+		case stderrors.Is(err, errors.ErrNotFound):
+			// The collection was dropped after it was listed.
+			continue
+		case err != nil:
+			return stats, err
+		}
+
+		stats.add(s)
+	}
+
+	return stats, nil
+}
+
+// collectionStats is a helper function for measuring the files stored directly
+// in a single collection.
+func (d *Driver) collectionStats(op *operation, collection string) (Stats, error) {
+	var stats Stats
+
+	mutex := d.getOrCreateLock(collection)
+	if err := op.rlock(mutex); err != nil {
+		return stats, err
+	}
+	defer mutex.RUnlock()
+
+	dir := filepath.Join(d.dir, collection)
+	err := walkCollection(dir, func(rel string, file fs.DirEntry) error {
+		if strings.HasSuffix(file.Name(), ".tmp") {
+			stats.OrphanedTmp++
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, errors.NewPathError(dir, err)
+	}
+
+	_, paths, err := recordFiles(dir)
+	if err != nil {
+		return stats, errors.NewPathError(dir, err)
+	}
+
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return stats, errors.NewPathError(path, err)
+		}
+
+		stats.add(Stats{
+			Records: 1,
+			Bytes:   fi.Size(),
+			Largest: fi.Size(),
+			Oldest:  fi.ModTime(),
+			Newest:  fi.ModTime(),
+		})
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,75 @@
+package scribble
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// TestStats tests the statistics of a collection and of the whole database.
+func TestStats(t *testing.T) {
+	dir := t.TempDir()
+
+	d, err := New(dir, nil)
+	if err != nil {
+		t.Fatal("Failed to create database: ", err.Error())
+	}
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Write(collection, "blue", Fish{Type: "a much longer blue"}); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Write("birds", "crow", Fish{Type: "black"}); err != nil {
+		t.Fatal("Create bird failed: ", err.Error())
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, collection, "green.json.tmp"), []byte("{"), 0644); err != nil {
+		t.Fatal("Failed to write temporary file: ", err.Error())
+	}
+
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(filepath.Join(dir, collection, "red.json"), old, old); err != nil {
+		t.Fatal("Failed to age record: ", err.Error())
+	}
+
+	red, _ := os.Stat(filepath.Join(dir, collection, "red.json"))
+	blue, _ := os.Stat(filepath.Join(dir, collection, "blue.json"))
+
+	stats, err := d.Stats(collection)
+	if err != nil {
+		t.Fatal("Stats failed: ", err.Error())
+	}
+
+	if stats.Records != 2 || stats.Bytes != red.Size()+blue.Size() || stats.Largest != blue.Size() {
+		t.Error("Expected two records sized ", red.Size(), " and ", blue.Size(), ", got: ", stats)
+	}
+
+	if !stats.Oldest.Equal(old) || !stats.Newest.Equal(blue.ModTime()) {
+		t.Error("Expected records written between ", old, " and ", blue.ModTime(), ", got: ", stats)
+	}
+
+	if stats.OrphanedTmp != 1 {
+		t.Error("Expected one orphaned temporary file, got: ", stats.OrphanedTmp)
+	}
+
+	all, err := d.DBStats()
+	if err != nil {
+		t.Fatal("DBStats failed: ", err.Error())
+	}
+
+	if all.Records != 3 || all.OrphanedTmp != 1 || !all.Oldest.Equal(old) {
+		t.Error("Expected three records across the database, got: ", all)
+	}
+
+	if _, err := d.Stats("missing"); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected ErrNotFound for a missing collection, got: ", err)
+	}
+}
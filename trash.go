@@ -0,0 +1,263 @@
+package scribble
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// Trash is the collection that Delete moves records and collections into when
+// soft delete is enabled. Each deletion gets its own entry, named by a
+// time-sortable ID, holding the deleted files in their original layout.
+const Trash = "_trash"
+
+// TrashEntry describes something deleted into the Trash.
+type TrashEntry struct {
+	ID         string    // ID identifies the entry for Restore
+	Collection string    // Collection is the collection the deleted data came from
+	Resource   string    // Resource is the deleted record, or empty for a whole collection
+	Time       time.Time // Time is when the data was deleted
+}
+
+// trashEntry is the sidecar file stored next to each entry in the Trash.
+type trashEntry struct {
+	Collection string `json:"collection"`
+	Resource   string `json:"resource,omitempty"`
+	Path       string `json:"path"` // Path is the slash-separated path of the deleted file or directory, relative to the root
+}
+
+// trash is a helper function for moving a deleted record file or collection
+// directory at src into a new entry in the Trash. The caller must hold the
+// locks of both the collection and the Trash.
+func (d *Driver) trash(collection, resource, src string) error {
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(d.dir, src)
+	if err != nil {
+		return err
+	}
+
+	dst := filepath.Join(d.dir, Trash, id, rel)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.NewFileIOError(dst, err)
+	}
+
+	if err := os.Rename(src, dst); err != nil {
+		return errors.NewFileIOError(src, err)
+	}
+
+	return d.saveTrashEntry(id, trashEntry{Collection: collection, Resource: resource, Path: filepath.ToSlash(rel)})
+}
+
+// saveTrashEntry is a helper function for writing the sidecar of an entry in
+// the Trash.
+func (d *Driver) saveTrashEntry(id string, entry trashEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(d.dir, Trash)
+	sidecar := filepath.Join(dir, id+recordExt)
+	return writeBytes(dir, sidecar+".tmp", sidecar, b)
+}
+
+// ListTrash returns the entries in the Trash, oldest first.
+func (d *Driver) ListTrash() (entries []TrashEntry, err error) {
+	op := d.begin(context.Background(), "list_trash", Trash, "")
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return nil, errors.ErrClosed
+	}
+
+	mutex := d.getOrCreateLock(Trash)
+	if err := op.rlock(mutex); err != nil {
+		return nil, err
+	}
+	defer mutex.RUnlock()
+
+	dir := filepath.Join(d.dir, Trash)
+	ids, err := resources(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.NewFileIOError(dir, err)
+	}
+
+	for _, id := range ids {
+		entry, err := d.trashEntry(id)
+		if err != nil {
+			return nil, err
+		}
+
+		t, _ := idTime(id)
+		entries = append(entries, TrashEntry{ID: id, Collection: entry.Collection, Resource: entry.Resource, Time: t})
+	}
+
+	return entries, nil
+}
+
+// trashEntry is a helper function for reading the sidecar of an entry in the Trash.
+func (d *Driver) trashEntry(id string) (trashEntry, error) {
+	var entry trashEntry
+
+	sidecar := filepath.Join(d.dir, Trash, id+recordExt)
+	b, err := os.ReadFile(sidecar)
+	if err != nil {
+		return entry, errors.NewPathError(sidecar, err)
+	}
+
+	if err := json.Unmarshal(b, &entry); err != nil || !filepath.IsLocal(filepath.FromSlash(entry.Path)) ||
+		checkPath(entry.Collection, entry.Resource) != nil {
+		return entry, errors.NewFileIOError(sidecar, errors.ErrInvalidRecord)
+	}
+
+	return entry, nil
+}
+
+// Restore moves an entry in the Trash, as listed by ListTrash, back to where
+// it was deleted from. It fails with ErrExists if a record or collection has
+// since been created in its place.
+func (d *Driver) Restore(id string) (err error) {
+	op := d.begin(context.Background(), "restore", Trash, id)
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return errors.ErrClosed
+	}
+
+	if d.readOnly {
+		return errors.ErrReadOnly
+	}
+
+	if id == "" {
+		return errors.ErrMissingResource
+	}
+
+	if !filepath.IsLocal(id) || path.Base(id) != id {
+		return errors.NewNotFoundError(path.Join(Trash, id), os.ErrNotExist)
+	}
+
+	// The entry names the collection to lock along with the Trash. Entries
+	// never change, but one may be purged before the locks are held, so it is
+	// read again once they are.
+	entry, err := d.trashEntry(id)
+	if err != nil {
+		return err
+	}
+
+	var unlock func()
+	if entry.Resource != "" {
+		unlock, err = d.lockCollections(op, Trash, entry.Collection)
+	} else {
+		unlock, err = d.lockCollectionTree(op, entry.Collection, Trash)
+	}
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := d.trashEntry(id); err != nil {
+		return err
+	}
+
+	target := filepath.Join(d.dir, entry.Collection)
+	if entry.Resource != "" {
+		target, err = d.findRecord(entry.Collection, target, entry.Resource)
+	} else {
+		_, err = os.Stat(target)
+	}
+	if err == nil {
+		return errors.NewFileIOError(target, errors.ErrExists)
+	} else if !os.IsNotExist(err) {
+		return errors.NewFileIOError(target, err)
+	}
+
+	dst := filepath.Join(d.dir, filepath.FromSlash(entry.Path))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.NewFileIOError(dst, err)
+	}
+
+	src := filepath.Join(d.dir, Trash, id, filepath.FromSlash(entry.Path))
+	if err := os.Rename(src, dst); err != nil {
+		return errors.NewPathError(src, err)
+	}
+
+	return d.removeTrashEntry(id)
+}
+
+// PurgeTrash permanently removes the entries in the Trash that were deleted
+// more than olderThan ago. It returns the number of entries removed.
+func (d *Driver) PurgeTrash(olderThan time.Duration) (n int, err error) {
+	op := d.begin(context.Background(), "purge_trash", Trash, "")
+	defer op.end(&err)
+
+	if d.closed.Load() {
+		return 0, errors.ErrClosed
+	}
+
+	if d.readOnly {
+		return 0, errors.ErrReadOnly
+	}
+
+	mutex := d.getOrCreateLock(Trash)
+	if err := op.lock(mutex); err != nil {
+		return 0, err
+	}
+	defer mutex.Unlock()
+
+	dir := filepath.Join(d.dir, Trash)
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, errors.NewFileIOError(dir, err)
+	}
+
+	now := time.Now()
+
+	// Entries are purged by their directories, which are moved into place
+	// before the sidecar is written, so interrupted deletions are purged too.
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+
+		t, ok := idTime(file.Name())
+		if !ok || now.Sub(t) < olderThan {
+			continue
+		}
+
+		if err := d.removeTrashEntry(file.Name()); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// removeTrashEntry is a helper function for removing an entry and its
+// sidecar from the Trash.
+func (d *Driver) removeTrashEntry(id string) error {
+	dir := filepath.Join(d.dir, Trash, id)
+	if err := os.RemoveAll(dir); err != nil {
+		return errors.NewFileIOError(dir, err)
+	}
+
+	sidecar := dir + recordExt
+	if err := os.Remove(sidecar); err != nil && !os.IsNotExist(err) {
+		return errors.NewFileIOError(sidecar, err)
+	}
+
+	return nil
+}
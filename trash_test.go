@@ -0,0 +1,183 @@
+package scribble
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/D7682/scribble/pkg/errors"
+)
+
+// createSoftDeleteDB creates a database with soft delete enabled, holding a red and a blue fish.
+func createSoftDeleteDB(t *testing.T) *Driver {
+	d := newTestDriver(t, t.TempDir(), &Options{SoftDelete: true})
+
+	if err := d.Write(collection, "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Write(collection, "blue", bluefish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	return d
+}
+
+// TestSoftDeleteRecord tests that a deleted record can be restored.
+func TestSoftDeleteRecord(t *testing.T) {
+	d := createSoftDeleteDB(t)
+
+	if err := d.Delete(collection, "red"); err != nil {
+		t.Fatal("Delete fish failed: ", err.Error())
+	}
+
+	if err := d.Read(collection, "red", &onefish); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Error("Expected deleted fish to be gone, got: ", err)
+	}
+
+	entries, err := d.ListTrash()
+	if err != nil || len(entries) != 1 {
+		t.Fatal("Expected one entry in the trash, got: ", entries, err)
+	}
+
+	if entries[0].Collection != collection || entries[0].Resource != "red" {
+		t.Error("Expected the red fish in the trash, got: ", entries[0])
+	}
+
+	if err := d.Restore(entries[0].ID); err != nil {
+		t.Fatal("Restore failed: ", err.Error())
+	}
+
+	if err := d.Read(collection, "red", &onefish); err != nil || onefish != redfish {
+		t.Error("Expected restored redfish, got: ", onefish, err)
+	}
+
+	if entries, err := d.ListTrash(); err != nil || len(entries) != 0 {
+		t.Error("Expected an empty trash, got: ", entries, err)
+	}
+}
+
+// TestSoftDeleteCollection tests that a deleted collection can be restored,
+// but not over one that has since been recreated.
+func TestSoftDeleteCollection(t *testing.T) {
+	d := createSoftDeleteDB(t)
+
+	if err := d.DropCollection(collection); err != nil {
+		t.Fatal("Delete school failed: ", err.Error())
+	}
+
+	entries, err := d.ListTrash()
+	if err != nil || len(entries) != 1 {
+		t.Fatal("Expected one entry in the trash, got: ", entries, err)
+	}
+
+	if collections, err := d.Collections(); err != nil || len(collections) != 0 {
+		t.Error("Expected the trash to be hidden from Collections, got: ", collections, err)
+	}
+
+	if err := d.Write(collection, "red", bluefish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Restore(entries[0].ID); !stderrors.Is(err, errors.ErrExists) {
+		t.Error("Expected ErrExists restoring over a new collection, got: ", err)
+	}
+
+	if err := d.Delete(collection, "red"); err != nil {
+		t.Fatal("Delete fish failed: ", err.Error())
+	}
+
+	if err := d.DropCollection(collection); err != nil {
+		t.Fatal("Delete school failed: ", err.Error())
+	}
+
+	if err := d.Restore(entries[0].ID); err != nil {
+		t.Fatal("Restore failed: ", err.Error())
+	}
+
+	if records, err := d.ReadAll(collection); err != nil || len(records) != 2 {
+		t.Error("Expected the original two fish, got: ", len(records), err)
+	}
+}
+
+// TestPurgeTrash tests that only entries older than the cutoff are purged.
+func TestPurgeTrash(t *testing.T) {
+	d := createSoftDeleteDB(t)
+
+	if err := d.Delete(collection, "red"); err != nil {
+		t.Fatal("Delete fish failed: ", err.Error())
+	}
+
+	if n, err := d.PurgeTrash(time.Hour); err != nil || n != 0 {
+		t.Error("Expected nothing to be purged, got: ", n, err)
+	}
+
+	if n, err := d.PurgeTrash(0); err != nil || n != 1 {
+		t.Error("Expected the entry to be purged, got: ", n, err)
+	}
+
+	if entries, err := d.ListTrash(); err != nil || len(entries) != 0 {
+		t.Error("Expected an empty trash, got: ", entries, err)
+	}
+}
+
+// TestTrashLockOrder tests that Restore takes its locks in the same order as
+// every other operation, so it never holds the Trash while waiting for a
+// collection that sorts before it, and that soft deletes wait for the Trash.
+func TestTrashLockOrder(t *testing.T) {
+	d := newTestDriver(t, t.TempDir(), &Options{SoftDelete: true})
+
+	if err := d.Write("Fish", "red", redfish); err != nil {
+		t.Fatal("Create fish failed: ", err.Error())
+	}
+
+	if err := d.Delete("Fish", "red"); err != nil {
+		t.Fatal("Delete fish failed: ", err.Error())
+	}
+
+	entries, err := d.ListTrash()
+	if err != nil || len(entries) != 1 {
+		t.Fatal("Expected one entry, got: ", entries, err)
+	}
+
+	mutex := d.getOrCreateLock("Fish")
+	mutex.Lock()
+
+	done := make(chan error)
+	go func() { done <- d.Restore(entries[0].ID) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Wait for the restore to queue for the collection; the Trash must stay free.
+	time.Sleep(20 * time.Millisecond)
+	if err := d.getOrCreateLock(Trash).RLockContext(ctx); err != nil {
+		t.Error("Expected the Trash to stay free while Restore waits, got: ", err)
+	} else {
+		d.getOrCreateLock(Trash).RUnlock()
+	}
+
+	mutex.Unlock()
+
+	if err := <-done; err != nil {
+		t.Fatal("Restore failed: ", err.Error())
+	}
+
+	trashMutex := d.getOrCreateLock(Trash)
+	trashMutex.Lock()
+
+	go func() { done <- d.Delete("Fish", "red") }()
+
+	select {
+	case err := <-done:
+		t.Fatal("Expected the delete to wait for the Trash, got: ", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	trashMutex.Unlock()
+
+	if err := <-done; err != nil {
+		t.Fatal("Delete fish failed: ", err.Error())
+	}
+}